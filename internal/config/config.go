@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -39,6 +41,28 @@ type Config struct {
 	JWT struct {
 		Secret string `yaml:"secret"`
 	} `yaml:"jwt"`
+
+	Bandit struct {
+		Interval time.Duration `yaml:"interval"` // How often bandit proxies are reweighted
+	} `yaml:"bandit"`
+
+	Rollout struct {
+		Interval time.Duration `yaml:"interval"` // How often rollout plans are checked for due steps
+	} `yaml:"rollout"`
+
+	Guardrails struct {
+		Interval time.Duration `yaml:"interval"` // How often guardrails are evaluated
+	} `yaml:"guardrails"`
+
+	Holdout struct {
+		Percentage float64 `yaml:"percentage"` // Share of users excluded from all experiments, from 0 to 100
+		Salt       string  `yaml:"salt"`       // Changing the salt reshuffles the holdout
+	} `yaml:"holdout"`
+
+	GeoIP struct {
+		Database       string        `yaml:"database"`        // Path to a MaxMind/GeoLite .mmdb file
+		ReloadInterval time.Duration `yaml:"reload_interval"` // How often the file is checked for changes
+	} `yaml:"geoip"`
 }
 
 func Load(path string) (*Config, error) {
@@ -52,5 +76,9 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.Holdout.Percentage < 0 || cfg.Holdout.Percentage > 100 {
+		return nil, fmt.Errorf("holdout percentage must be between 0 and 100")
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,180 @@
+package geo
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Unknown is returned for every field when the location cannot be resolved
+const Unknown = "unknown"
+
+// Location holds the codes an IP address resolves to
+type Location struct {
+	Country   string // ISO 3166-1 country code, e.g. "DE"
+	Continent string // Continent code, e.g. "EU"
+	Region    string // ISO 3166-2 subdivision code, e.g. "DE-BY"
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// Resolver looks up client IPs in a MaxMind/GeoLite database file and
+// reopens the file when it changes on disk
+type Resolver struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+// NewResolver opens the database at path. An empty path or a file that cannot
+// be opened yields a resolver that returns unknown locations until the file appears.
+func NewResolver(path string) *Resolver {
+	r := &Resolver{path: path}
+	if path != "" {
+		if err := r.reload(); err != nil {
+			log.Printf("Failed to open GeoIP database %s: %v", path, err)
+		}
+	}
+	return r
+}
+
+// Lookup resolves the IP address, returning Unknown for fields that cannot be resolved
+func (r *Resolver) Lookup(ip string) Location {
+	location := Location{Country: Unknown, Continent: Unknown, Region: Unknown}
+	if r == nil {
+		return location
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return location
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.reader == nil {
+		return location
+	}
+
+	var rec record
+	if err := r.reader.Lookup(addr, &rec); err != nil {
+		return location
+	}
+
+	if rec.Country.ISOCode != "" {
+		location.Country = rec.Country.ISOCode
+	}
+	if rec.Continent.Code != "" {
+		location.Continent = rec.Continent.Code
+	}
+	if len(rec.Subdivisions) > 0 && rec.Subdivisions[0].ISOCode != "" && rec.Country.ISOCode != "" {
+		location.Region = rec.Country.ISOCode + "-" + rec.Subdivisions[0].ISOCode
+	}
+	return location
+}
+
+// Field returns the location code for the given field name: "country" (default), "continent" or "region"
+func (l Location) Field(name string) string {
+	switch strings.ToLower(name) {
+	case "continent":
+		return l.Continent
+	case "region":
+		return l.Region
+	default:
+		return l.Country
+	}
+}
+
+// Watch polls the database file and reloads it when its modification time or size changes
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration) {
+	if r.path == "" {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("Failed to reload GeoIP database %s: %v", r.path, err)
+			} else {
+				log.Printf("Reloaded GeoIP database %s", r.path)
+			}
+		}
+	}
+}
+
+// Close releases the open database
+func (r *Resolver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reader == nil {
+		return nil
+	}
+	err := r.reader.Close()
+	r.reader = nil
+	return err
+}
+
+func (r *Resolver) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+}
+
+func (r *Resolver) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := maxminddb.Open(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reader != nil {
+		r.reader.Close()
+	}
+	r.reader = reader
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	return nil
+}
@@ -0,0 +1,41 @@
+package models
+
+type AssignmentMode string
+
+const (
+	AssignmentModeRandom AssignmentMode = "random"
+	AssignmentModeHash   AssignmentMode = "hash"
+)
+
+func (m AssignmentMode) IsValid() bool {
+	switch m {
+	case AssignmentModeRandom, AssignmentModeHash:
+		return true
+	}
+	return false
+}
+
+type IdentitySource string
+
+const (
+	IdentitySourceRUID   IdentitySource = "ruid"
+	IdentitySourceHeader IdentitySource = "header"
+	IdentitySourceCookie IdentitySource = "cookie"
+	IdentitySourceQuery  IdentitySource = "query"
+)
+
+func (s IdentitySource) IsValid() bool {
+	switch s {
+	case IdentitySourceRUID, IdentitySourceHeader, IdentitySourceCookie, IdentitySourceQuery:
+		return true
+	}
+	return false
+}
+
+// Assignment describes how users without a sticky cookie are bucketed into targets
+type Assignment struct {
+	Mode      AssignmentMode `json:"mode" db:"mode"`                  // "random" (default) or "hash"
+	Salt      string         `json:"salt,omitempty" db:"salt"`        // Per-proxy salt, defaults to the proxy ID
+	Source    IdentitySource `json:"source,omitempty" db:"source"`    // Identity to hash: "ruid" (default), "header", "cookie", "query"
+	ParamName string         `json:"param_name,omitempty" db:"param"` // Name of the header, cookie or query parameter
+}
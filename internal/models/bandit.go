@@ -0,0 +1,21 @@
+package models
+
+// BanditSettings turns on automatic reweighting of targets towards the best
+// converting one using Thompson sampling
+type BanditSettings struct {
+	Enabled        bool    `json:"enabled" db:"enabled"`
+	MinExploration float64 `json:"min_exploration" db:"min_exploration"` // Minimum weight every active target keeps, e.g. 0.05
+}
+
+// BanditCounters are the outcomes recorded for a target since the bandit started
+type BanditCounters struct {
+	Exposures   int64 `json:"exposures"` // Exposed requests, repeat visits of a user count again
+	Conversions int64 `json:"conversions"`
+}
+
+// BanditArm is the sampled state of a target after a reweighting
+type BanditArm struct {
+	BanditCounters
+	Probability float64 `json:"probability"` // Probability of being the best target
+	Weight      float64 `json:"weight"`      // Weight assigned after applying the exploration floor
+}
@@ -0,0 +1,9 @@
+package models
+
+// ConsentPolicy makes a proxy set no identifier cookies until the user consents
+type ConsentPolicy struct {
+	Enabled bool           `json:"enabled"`          // The policy is kept but not applied while disabled, every user consents
+	Source  IdentitySource `json:"source"`           // Where the consent signal is read from: "cookie" or "header"
+	Name    string         `json:"name"`             // Name of the cookie or header
+	Values  []string       `json:"values,omitempty"` // Values that grant consent, any non-empty value by default
+}
@@ -0,0 +1,12 @@
+package models
+
+// CookiePolicy configures the cookies a proxy sets, the name and lifetime apply
+// to the sticky assignment cookie. Zero values fall back to the defaults
+type CookiePolicy struct {
+	Name       string `json:"name,omitempty"` // proxy_<id> by default
+	Domain     string `json:"domain,omitempty"`
+	Path       string `json:"path,omitempty"`        // / by default
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // 30 days by default
+	Secure     bool   `json:"secure"`
+	SameSite   string `json:"same_site,omitempty"` // lax, strict or none, lax by default
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// DiffSettings compares the responses of the primary and shadow targets
+// for a sample of mirrored requests
+type DiffSettings struct {
+	SampleRate   float64  `json:"sample_rate"`    // Share of mirrored requests that are compared, above 0 and up to 1, required
+	Headers      []string `json:"headers"`        // Response headers that must match
+	IgnoreFields []string `json:"ignore_fields"`  // Dot separated JSON paths left out of the comparison, "*" matches any key or index
+	MaxBodyBytes int64    `json:"max_body_bytes"` // Larger bodies are not compared, 1 MiB by default
+}
+
+// FieldDiff is a header or JSON field whose value differs between the responses
+type FieldDiff struct {
+	Path    string `json:"path"`
+	Primary any    `json:"primary"`
+	Shadow  any    `json:"shadow"`
+}
+
+// ResponseDiff is an example of a mismatch between the primary and a shadow response
+type ResponseDiff struct {
+	Shadow        string      `json:"shadow"`
+	Method        string      `json:"method"`
+	Path          string      `json:"path"`
+	PrimaryStatus int         `json:"primary_status"`
+	ShadowStatus  int         `json:"shadow_status"`
+	Headers       []FieldDiff `json:"headers,omitempty"`
+	Fields        []FieldDiff `json:"fields,omitempty"`
+	BodyDiffers   bool        `json:"body_differs,omitempty"` // Non-JSON bodies are compared byte by byte
+	Error         string      `json:"error,omitempty"`        // The shadow request failed
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// DiffSummary counts the compared and mismatching responses of a shadow target
+type DiffSummary struct {
+	Shadow     string `json:"shadow"`
+	Compared   int64  `json:"compared"`
+	Mismatched int64  `json:"mismatched"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// GuardrailSettings roll a target back to zero weight when it performs worse
+// than allowed. A rule with a zero threshold is disabled
+type GuardrailSettings struct {
+	MaxErrorRatio   float64 `json:"max_error_ratio"`   // Share of requests that failed or got a 5xx response, from 0 to 1
+	MaxLatencyRatio float64 `json:"max_latency_ratio"` // p95 latency relative to the control target, e.g. 1.5
+	MinRequests     int64   `json:"min_requests"`      // Requests a target needs in the window before it is evaluated
+	WindowSeconds   int64   `json:"window_seconds"`    // Length of the evaluation window, 10 minutes by default
+}
+
+// defaultGuardrailWindow is used when WindowSeconds is not set
+const defaultGuardrailWindow = 10 * time.Minute
+
+func (g *GuardrailSettings) Window() time.Duration {
+	if g.WindowSeconds > 0 {
+		return time.Duration(g.WindowSeconds) * time.Second
+	}
+	return defaultGuardrailWindow
+}
+
+// Enabled reports whether any guardrail rule is set
+func (g *GuardrailSettings) Enabled() bool {
+	return g != nil && (g.MaxErrorRatio > 0 || g.MaxLatencyRatio > 0)
+}
+
+// GuardrailSample is what a target served during a guardrail window
+type GuardrailSample struct {
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	Latency  []int64 `json:"latency"` // Requests per latency bucket, see proxy.LatencyBuckets
+}
+
+// GuardrailEvent is emitted when a guardrail rolls a target back
+type GuardrailEvent struct {
+	Event          string    `json:"event"`
+	ProxyID        string    `json:"proxy_id"`
+	TargetID       string    `json:"target_id"`
+	Rule           string    `json:"rule"` // error_ratio or latency_ratio
+	Value          float64   `json:"value"`
+	Threshold      float64   `json:"threshold"`
+	Requests       int64     `json:"requests"`
+	PreviousWeight float64   `json:"previous_weight"`
+	CreatedAt      time.Time `json:"created_at"`
+}
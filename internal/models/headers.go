@@ -0,0 +1,33 @@
+package models
+
+type HeaderAction string
+
+const (
+	HeaderActionSet    HeaderAction = "set"
+	HeaderActionAdd    HeaderAction = "add"
+	HeaderActionRemove HeaderAction = "remove"
+)
+
+func (a HeaderAction) IsValid() bool {
+	switch a {
+	case HeaderActionSet, HeaderActionAdd, HeaderActionRemove:
+		return true
+	}
+	return false
+}
+
+// HeaderRules rewrite the headers of reverse proxied requests and responses.
+// Proxy rules are applied first, the rules of the target after them
+type HeaderRules struct {
+	Request  []HeaderRule `json:"request,omitempty"`
+	Response []HeaderRule `json:"response,omitempty"`
+}
+
+// HeaderRule changes one header. Values may contain {ruid}, {rrid}, {rid} and
+// {target_id}, which are replaced for every request. Setting the Host request
+// header overrides the host sent to the target
+type HeaderRule struct {
+	Action HeaderAction `json:"action"`
+	Name   string       `json:"name"`
+	Value  string       `json:"value,omitempty"`
+}
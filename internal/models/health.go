@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// HealthCheck actively probes a target, unhealthy targets are left out of the
+// selection until they recover. Zero values fall back to the defaults
+type HealthCheck struct {
+	Path               string `json:"path"`                // Request path on the target host, e.g. "/healthz"
+	IntervalSeconds    int    `json:"interval_seconds"`    // Time between probes, 10 seconds by default
+	TimeoutMs          int    `json:"timeout_ms"`          // Timeout of a probe, 2 seconds by default
+	ExpectedStatus     int    `json:"expected_status"`     // Status code of a healthy response, any 2xx by default
+	HealthyThreshold   int    `json:"healthy_threshold"`   // Consecutive successes to become healthy, 2 by default
+	UnhealthyThreshold int    `json:"unhealthy_threshold"` // Consecutive failures to become unhealthy, 3 by default
+}
+
+// TargetHealth is the health check state of a target on one service instance
+type TargetHealth struct {
+	TargetID             string     `json:"target_id"`
+	URL                  string     `json:"url"`
+	Healthy              bool       `json:"healthy"`
+	ConsecutiveSuccesses int        `json:"consecutive_successes"`
+	ConsecutiveFailures  int        `json:"consecutive_failures"`
+	LastStatus           int        `json:"last_status,omitempty"`
+	LastError            string     `json:"last_error,omitempty"`
+	LastCheckedAt        *time.Time `json:"last_checked_at,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LayerBuckets is the size of the hash space shared by the proxies of a layer
+const LayerBuckets = 1000
+
+// Layer is a namespace of mutually exclusive experiments: every user falls into
+// exactly one bucket of a layer and every bucket belongs to at most one proxy
+type Layer struct {
+	ID          string            `json:"id" db:"id"`
+	Name        string            `json:"name" db:"name"`
+	Description string            `json:"description" db:"description"`
+	Allocations []LayerAllocation `json:"allocations"`
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// LayerAllocation is the range of buckets [BucketStart, BucketEnd) a proxy owns in a layer
+type LayerAllocation struct {
+	LayerID     string `json:"layer_id" db:"layer_id"`
+	ProxyID     string `json:"proxy_id,omitempty" db:"proxy_id"`
+	BucketStart int    `json:"bucket_start" db:"layer_bucket_start"`
+	BucketEnd   int    `json:"bucket_end" db:"layer_bucket_end"`
+}
+
+// Validate checks that the range is a non-empty part of the layer
+func (a *LayerAllocation) Validate() error {
+	if a.LayerID == "" {
+		return errors.New("layer_id is required")
+	}
+	if a.BucketStart < 0 || a.BucketEnd > LayerBuckets || a.BucketStart >= a.BucketEnd {
+		return fmt.Errorf("bucket range must satisfy 0 <= bucket_start < bucket_end <= %d", LayerBuckets)
+	}
+	return nil
+}
+
+// Overlaps reports whether two ranges of the same layer share a bucket
+func (a LayerAllocation) Overlaps(b LayerAllocation) bool {
+	return a.LayerID == b.LayerID && a.BucketStart < b.BucketEnd && b.BucketStart < a.BucketEnd
+}
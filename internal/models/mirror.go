@@ -0,0 +1,12 @@
+package models
+
+// MirrorSettings replays a sample of reverse proxied requests to shadow targets
+// whose responses are discarded
+type MirrorSettings struct {
+	Targets      []string `json:"targets"`        // Shadow target URLs
+	SampleRate   float64  `json:"sample_rate"`    // Share of requests that are mirrored, above 0 and up to 1, required
+	MaxBodyBytes int64    `json:"max_body_bytes"` // Requests with larger bodies are not mirrored, 1 MiB by default
+	TimeoutMs    int      `json:"timeout_ms"`     // Timeout of a shadow request, 5 seconds by default
+	// Compare shadow responses with the primary response
+	Diff *DiffSettings `json:"diff,omitempty"`
+}
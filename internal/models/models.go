@@ -19,12 +19,28 @@ const (
 	ConditionTypeCookie    ConditionType = "cookie"
 	ConditionTypeUserAgent ConditionType = "user_agent"
 	ConditionTypeLanguage  ConditionType = "language"
+	ConditionTypePath      ConditionType = "path"
+	ConditionTypeMethod    ConditionType = "method"
+	ConditionTypeHost      ConditionType = "host"
+	ConditionTypeIP        ConditionType = "ip"
+	ConditionTypeGeo       ConditionType = "geo"
 )
 
 func (ct ConditionType) IsValid() bool {
 	switch ct {
 	case ConditionTypeHeader, ConditionTypeQuery, ConditionTypeCookie,
-		ConditionTypeUserAgent, ConditionTypeLanguage:
+		ConditionTypeUserAgent, ConditionTypeLanguage,
+		ConditionTypePath, ConditionTypeMethod, ConditionTypeHost, ConditionTypeIP,
+		ConditionTypeGeo:
+		return true
+	}
+	return false
+}
+
+// RequiresParam reports whether the condition type needs a param_name to extract its value
+func (ct ConditionType) RequiresParam() bool {
+	switch ct {
+	case ConditionTypeHeader, ConditionTypeQuery, ConditionTypeCookie, ConditionTypeUserAgent:
 		return true
 	}
 	return false
@@ -32,21 +48,37 @@ func (ct ConditionType) IsValid() bool {
 
 // RouteCondition represents a condition for routing traffic
 type RouteCondition struct {
-	Type      ConditionType     `json:"type" db:"type"`        // Type of condition: "header", "query", "cookie", "user_agent", "language"
-	ParamName string            `json:"param_name" db:"param"` // Name of the parameter to check (for header, query, cookie)
-	Values    map[string]string `json:"values" db:"values"`    // List of values to match targets by id
-	Default   string            `json:"default" db:"default"`  // Default target ID if no match is found
+	Type      ConditionType     `json:"type" db:"type"`                   // Type of condition: "header", "query", "cookie", "user_agent", "language", "path", "method", "host", "ip", "geo"
+	ParamName string            `json:"param_name" db:"param"`            // Name of the parameter to check (for header, query, cookie), or geo field: "country", "continent", "region"
+	Operator  MatchOperator     `json:"operator,omitempty" db:"operator"` // How values are compared, "eq" by default
+	Values    map[string]string `json:"values" db:"values"`               // List of values to match targets by id
+	Default   string            `json:"default" db:"default"`             // Default target ID if no match is found
+	Rules     []RouteRule       `json:"rules,omitempty" db:"rules"`       // Rule trees evaluated in order before the single condition
 }
 
 type Proxy struct {
-	ID        string          `json:"id" db:"id"`
-	Mode      string          `json:"mode" db:"mode"`
-	ListenURL string          `json:"listen_url" db:"listen_url"`
-	Targets   []Target        `json:"targets" db:"targets"`
-	Condition *RouteCondition `json:"condition,omitempty" db:"condition"`
-	Tags      []string        `json:"tags" db:"tags"`
-	CreatedAt time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+	ID               string             `json:"id" db:"id"`
+	Mode             string             `json:"mode" db:"mode"`
+	ListenURL        string             `json:"listen_url" db:"listen_url"`
+	Targets          []Target           `json:"targets" db:"targets"`
+	Condition        *RouteCondition    `json:"condition,omitempty" db:"condition"`
+	Assignment       *Assignment        `json:"assignment,omitempty" db:"assignment"`
+	TrustedProxies   []string           `json:"trusted_proxies,omitempty" db:"trusted_proxies"`
+	Schedule         *Schedule          `json:"schedule,omitempty" db:"schedule"`
+	Bandit           *BanditSettings    `json:"bandit,omitempty" db:"bandit"`
+	Strategy         string             `json:"strategy,omitempty" db:"strategy"`
+	Layer            *LayerAllocation   `json:"layer,omitempty"`
+	Mirror           *MirrorSettings    `json:"mirror,omitempty" db:"mirror"`
+	Guardrails       *GuardrailSettings `json:"guardrails,omitempty" db:"guardrails"`
+	OutlierDetection *OutlierDetection  `json:"outlier_detection,omitempty" db:"outlier_detection"`
+	Retry            *RetryPolicy       `json:"retry,omitempty" db:"retry_policy"`
+	Transport        *TransportSettings `json:"transport,omitempty" db:"transport"`
+	Headers          *HeaderRules       `json:"headers,omitempty" db:"headers"`
+	Cookie           *CookiePolicy      `json:"cookie,omitempty" db:"cookie_policy"`
+	Consent          *ConsentPolicy     `json:"consent,omitempty" db:"consent_policy"`
+	Tags             []string           `json:"tags" db:"tags"`
+	CreatedAt        time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at" db:"updated_at"`
 }
 
 type Target struct {
@@ -54,7 +86,15 @@ type Target struct {
 	URL      string  `json:"url" db:"url"`
 	Weight   float64 `json:"weight" db:"weight"`
 	IsActive bool    `json:"is_active" db:"is_active"`
-	ProxyID  string  `json:"proxy_id" db:"proxy_id"`
+	// Users who are not part of the experiment are sent to the control target
+	IsControl bool   `json:"is_control" db:"is_control"`
+	ProxyID   string `json:"proxy_id" db:"proxy_id"`
+	// Active health check, unhealthy targets get no new users until they recover
+	HealthCheck *HealthCheck `json:"health_check,omitempty" db:"health_check"`
+	// Header rewrite rules applied after the rules of the proxy
+	Headers *HeaderRules `json:"headers,omitempty" db:"headers"`
+	// Maps the request path onto the path of the target
+	PathRewrite *PathRewrite `json:"path_rewrite,omitempty" db:"path_rewrite"`
 }
 
 type Visit struct {
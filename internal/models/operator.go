@@ -0,0 +1,30 @@
+package models
+
+type MatchOperator string
+
+const (
+	MatchOperatorEquals   MatchOperator = "eq"       // Value equals one of the values (default)
+	MatchOperatorIn       MatchOperator = "in"       // Same as eq, reads better for long lists
+	MatchOperatorRegex    MatchOperator = "regex"    // Value matches one of the regular expressions
+	MatchOperatorPrefix   MatchOperator = "prefix"   // Value starts with one of the values
+	MatchOperatorSuffix   MatchOperator = "suffix"   // Value ends with one of the values
+	MatchOperatorContains MatchOperator = "contains" // Value contains one of the values
+	MatchOperatorGT       MatchOperator = "gt"       // Numeric value is greater than the single value
+	MatchOperatorGTE      MatchOperator = "gte"      // Numeric value is greater than or equal to the single value
+	MatchOperatorLT       MatchOperator = "lt"       // Numeric value is less than the single value
+	MatchOperatorLTE      MatchOperator = "lte"      // Numeric value is less than or equal to the single value
+	MatchOperatorVersion  MatchOperator = "version"  // Version satisfies one of the ranges, e.g. ">=3.2.0 <4.0.0"
+	MatchOperatorGlob     MatchOperator = "glob"     // Value matches one of the globs, "*" within a path segment, "**" across segments
+	MatchOperatorCIDR     MatchOperator = "cidr"     // IP address is within one of the CIDRs (default for ip conditions)
+)
+
+func (op MatchOperator) IsValid() bool {
+	switch op {
+	case MatchOperatorEquals, MatchOperatorIn, MatchOperatorRegex, MatchOperatorPrefix,
+		MatchOperatorSuffix, MatchOperatorContains, MatchOperatorGT, MatchOperatorGTE,
+		MatchOperatorLT, MatchOperatorLTE, MatchOperatorVersion, MatchOperatorGlob,
+		MatchOperatorCIDR:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// OutlierDetection ejects a target from the selection after consecutive failed
+// requests. When the ejection ends the target is half-open: a success restores it,
+// a failure ejects it again for twice as long. Zero values fall back to the defaults
+type OutlierDetection struct {
+	Enabled             bool `json:"enabled"`               // Settings are kept but not applied while disabled
+	ConsecutiveErrors   int  `json:"consecutive_errors"`    // 5xx responses or transport errors in a row, 5 by default
+	BaseEjectionSeconds int  `json:"base_ejection_seconds"` // Length of the first ejection, 30 seconds by default
+	MaxEjectionSeconds  int  `json:"max_ejection_seconds"`  // Upper bound of the back-off, 5 minutes by default
+	// Target for users whose sticky target is ejected, they are assigned anew when empty
+	FallbackTargetID string `json:"fallback_target_id,omitempty"`
+}
+
+// TargetEjection is the outlier detection state of a target on one service instance
+type TargetEjection struct {
+	TargetID          string     `json:"target_id"`
+	Ejected           bool       `json:"ejected"`
+	HalfOpen          bool       `json:"half_open"` // Restored on the next success, ejected again on the next failure
+	ConsecutiveErrors int        `json:"consecutive_errors"`
+	Ejections         int        `json:"ejections"` // Ejections in a row without a success in between
+	EjectedUntil      *time.Time `json:"ejected_until,omitempty"`
+}
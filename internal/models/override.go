@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Override always sends a user to a target regardless of the proxy's strategy,
+// so QA and staff can preview a specific variant
+type Override struct {
+	ID        string    `json:"id" db:"id"`
+	ProxyID   string    `json:"proxy_id" db:"proxy_id"`
+	Identity  string    `json:"identity" db:"identity"` // RUID or the value of the proxy's identity source, e.g. a user ID
+	TargetID  string    `json:"target_id" db:"target_id"`
+	Note      string    `json:"note" db:"note"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	CreatedBy *string   `json:"created_by,omitempty" db:"created_by"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// PathRewrite maps the request path onto the path of a target, for example
+// /new-checkout/* onto /checkout/* by stripping /new-checkout and adding
+// /checkout. The steps run in order: strip prefix, regex replace, add prefix
+type PathRewrite struct {
+	StripPrefix string `json:"strip_prefix,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"` // May refer to groups of Regex as $1 or ${name}
+	AddPrefix   string `json:"add_prefix,omitempty"`
+}
@@ -8,8 +8,26 @@ import (
 type ChangeType string
 
 const (
-	ChangeTypeTargetsUpdate   ChangeType = "targets_update"
-	ChangeTypeConditionUpdate ChangeType = "condition_update"
+	ChangeTypeTargetsUpdate          ChangeType = "targets_update"
+	ChangeTypeConditionUpdate        ChangeType = "condition_update"
+	ChangeTypeAssignmentUpdate       ChangeType = "assignment_update"
+	ChangeTypeTrustedProxies         ChangeType = "trusted_proxies_update"
+	ChangeTypeScheduleUpdate         ChangeType = "schedule_update"
+	ChangeTypeBanditUpdate           ChangeType = "bandit_update"
+	ChangeTypeBanditReweight         ChangeType = "bandit_reweight"
+	ChangeTypeStrategyUpdate         ChangeType = "strategy_update"
+	ChangeTypeLayerUpdate            ChangeType = "layer_update"
+	ChangeTypeMirrorUpdate           ChangeType = "mirror_update"
+	ChangeTypeRolloutStep            ChangeType = "rollout_step"
+	ChangeTypeRolloutAbort           ChangeType = "rollout_abort"
+	ChangeTypeGuardrailsUpdate       ChangeType = "guardrails_update"
+	ChangeTypeGuardrailRollback      ChangeType = "guardrail_rollback"
+	ChangeTypeOutlierDetectionUpdate ChangeType = "outlier_detection_update"
+	ChangeTypeRetryUpdate            ChangeType = "retry_update"
+	ChangeTypeTransportUpdate        ChangeType = "transport_update"
+	ChangeTypeHeadersUpdate          ChangeType = "headers_update"
+	ChangeTypeCookieUpdate           ChangeType = "cookie_update"
+	ChangeTypeConsentUpdate          ChangeType = "consent_update"
 )
 
 type ProxyChange struct {
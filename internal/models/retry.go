@@ -0,0 +1,16 @@
+package models
+
+// RetryPolicy retries requests whose upstream connection failed, on the same or
+// the next available target. Zero values fall back to the defaults
+type RetryPolicy struct {
+	Enabled      bool     `json:"enabled"`           // The policy is kept but not applied while disabled
+	Attempts     int      `json:"attempts"`          // Retries per request, 1 by default
+	Methods      []string `json:"methods,omitempty"` // GET, HEAD and OPTIONS by default, other methods are opt-in
+	MaxBodyBytes int64    `json:"max_body_bytes"`    // Requests with larger bodies are not retried, 64 KiB by default
+	SameTarget   bool     `json:"same_target"`       // Retry the failed target instead of the next available one
+	// Retries allowed as a share of the retryable requests of the last 10 seconds,
+	// so that retries cannot multiply the load during an outage. 0.2 by default
+	BudgetRatio float64 `json:"budget_ratio"`
+	// Retries always allowed per 10 seconds regardless of the ratio, 3 by default
+	BudgetMinRetries int `json:"budget_min_retries"`
+}
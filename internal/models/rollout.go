@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type RolloutStatus string
+
+const (
+	RolloutStatusRunning   RolloutStatus = "running"
+	RolloutStatusPaused    RolloutStatus = "paused"
+	RolloutStatusCompleted RolloutStatus = "completed"
+	RolloutStatusAborted   RolloutStatus = "aborted"
+)
+
+// IsActive reports whether the rollout still owns the weights of its proxy
+func (s RolloutStatus) IsActive() bool {
+	return s == RolloutStatusRunning || s == RolloutStatusPaused
+}
+
+// RolloutStep gives the canary target a share of the traffic for a duration
+type RolloutStep struct {
+	Weight          float64 `json:"weight"`           // Share of the canary target, from 0 to 1
+	DurationSeconds int64   `json:"duration_seconds"` // How long the step is held before the next one
+}
+
+func (s RolloutStep) Duration() time.Duration {
+	return time.Duration(s.DurationSeconds) * time.Second
+}
+
+// Rollout progressively ramps traffic to a canary target. The other active
+// targets share the rest in proportion to their weights when the rollout started
+type Rollout struct {
+	ID            string             `json:"id" db:"id"`
+	ProxyID       string             `json:"proxy_id" db:"proxy_id"`
+	TargetID      string             `json:"target_id" db:"target_id"`
+	Steps         []RolloutStep      `json:"steps" db:"steps"`
+	BaseWeights   map[string]float64 `json:"base_weights" db:"base_weights"` // Weights of the other targets when the rollout started
+	CurrentStep   int                `json:"current_step" db:"current_step"` // Index of the applied step, -1 before the first one
+	Status        RolloutStatus      `json:"status" db:"status"`
+	StepStartedAt *time.Time         `json:"step_started_at,omitempty" db:"step_started_at"`
+	PausedAt      *time.Time         `json:"paused_at,omitempty" db:"paused_at"`
+	CreatedAt     time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" db:"updated_at"`
+	CreatedBy     *string            `json:"created_by,omitempty" db:"created_by"`
+}
+
+// NextStepAt returns when the current step ends, nil if no step is running
+func (r *Rollout) NextStepAt() *time.Time {
+	if r.Status != RolloutStatusRunning || r.StepStartedAt == nil || r.CurrentStep < 0 || r.CurrentStep >= len(r.Steps) {
+		return nil
+	}
+	next := r.StepStartedAt.Add(r.Steps[r.CurrentStep].Duration())
+	return &next
+}
+
+func ValidateRolloutSteps(steps []RolloutStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("rollout must have at least one step")
+	}
+	for i, step := range steps {
+		if step.Weight < 0 || step.Weight > 1 {
+			return fmt.Errorf("step %d: weight must be between 0 and 1", i)
+		}
+		if step.DurationSeconds < 0 {
+			return fmt.Errorf("step %d: duration must not be negative", i)
+		}
+	}
+	return nil
+}
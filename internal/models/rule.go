@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RuleNode is a node of a routing rule tree. A node is either a group
+// (all/any/not of nested nodes) or a leaf predicate over a request value.
+type RuleNode struct {
+	All []RuleNode `json:"all,omitempty"` // Matches if every nested node matches
+	Any []RuleNode `json:"any,omitempty"` // Matches if at least one nested node matches
+	Not *RuleNode  `json:"not,omitempty"` // Matches if the nested node does not match
+
+	Type      ConditionType `json:"type,omitempty"`       // Source of the leaf value, same as RouteCondition.Type
+	ParamName string        `json:"param_name,omitempty"` // Name of the parameter to check (for header, query, cookie, user_agent)
+	Operator  MatchOperator `json:"operator,omitempty"`   // How the value is compared, "eq" by default
+	Values    []string      `json:"values,omitempty"`     // Leaf matches if the value matches any of these
+}
+
+// RouteRule sends requests matching the rule tree to the given target
+type RouteRule struct {
+	TargetID string   `json:"target_id"`
+	Match    RuleNode `json:"match"`
+}
+
+// Validate checks that every node of the tree is either a well-formed group or a leaf
+func (n *RuleNode) Validate() error {
+	kinds := 0
+	if len(n.All) > 0 {
+		kinds++
+	}
+	if len(n.Any) > 0 {
+		kinds++
+	}
+	if n.Not != nil {
+		kinds++
+	}
+	if n.Type != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return errors.New("rule node must have exactly one of all, any, not or type")
+	}
+
+	for i := range n.All {
+		if err := n.All[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range n.Any {
+		if err := n.Any[i].Validate(); err != nil {
+			return err
+		}
+	}
+	if n.Not != nil {
+		return n.Not.Validate()
+	}
+
+	if n.Type != "" {
+		if !n.Type.IsValid() {
+			return fmt.Errorf("invalid condition type in rule: %s", n.Type)
+		}
+		if n.Type.RequiresParam() && n.ParamName == "" {
+			return fmt.Errorf("param_name is required for %s rule", n.Type)
+		}
+		if n.Operator != "" && !n.Operator.IsValid() {
+			return fmt.Errorf("invalid operator in rule: %s", n.Operator)
+		}
+		if len(n.Values) == 0 {
+			return fmt.Errorf("values are required for %s rule", n.Type)
+		}
+	}
+	return nil
+}
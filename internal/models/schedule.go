@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Schedule limits when a proxy runs its experiment. Outside of the schedule
+// all traffic goes to the default target.
+type Schedule struct {
+	Start    *time.Time       `json:"start,omitempty"`    // Experiment is inactive before this moment
+	End      *time.Time       `json:"end,omitempty"`      // Experiment is inactive from this moment on
+	Windows  []ScheduleWindow `json:"windows,omitempty"`  // Recurring windows, always active within start/end if empty
+	Timezone string           `json:"timezone,omitempty"` // IANA timezone the windows are evaluated in, UTC by default
+}
+
+// ScheduleWindow is a recurring weekly window, e.g. mon-fri 09:00-18:00
+type ScheduleWindow struct {
+	Weekdays  []string `json:"weekdays,omitempty"`   // "mon".."sun", every day if empty
+	StartTime string   `json:"start_time,omitempty"` // "HH:MM", inclusive, midnight if empty
+	EndTime   string   `json:"end_time,omitempty"`   // "HH:MM", exclusive, end of day if empty; before start_time spans midnight
+}
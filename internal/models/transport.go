@@ -0,0 +1,11 @@
+package models
+
+// TransportSettings tune the connections from a proxy to its targets, every
+// target keeps its own connection pool. Zero values fall back to the defaults
+type TransportSettings struct {
+	MaxIdleConns            int `json:"max_idle_conns"`             // Idle connections kept per target, 100 by default
+	IdleTimeoutSeconds      int `json:"idle_timeout_seconds"`       // 90 by default
+	DialTimeoutMs           int `json:"dial_timeout_ms"`            // 10 seconds by default
+	TLSHandshakeTimeoutMs   int `json:"tls_handshake_timeout_ms"`   // 10 seconds by default
+	ResponseHeaderTimeoutMs int `json:"response_header_timeout_ms"` // No timeout by default
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// isHashAssignment reports whether targets are chosen by hashing the user identity
+func (p *Proxy) isHashAssignment() bool {
+	return p.Config.Assignment != nil && p.Config.Assignment.Mode == models.AssignmentModeHash
+}
+
+// assignmentSalt returns the configured salt or the proxy ID if none is set
+func (p *Proxy) assignmentSalt() string {
+	if p.Config.Assignment != nil && p.Config.Assignment.Salt != "" {
+		return p.Config.Assignment.Salt
+	}
+	return p.ID
+}
+
+// identity extracts the value users are bucketed by, falling back to the RUID
+func (p *Proxy) identity(r *http.Request, info *RedirectInfo) string {
+	var value string
+	if a := p.Config.Assignment; a != nil {
+		switch a.Source {
+		case models.IdentitySourceHeader:
+			value = r.Header.Get(a.ParamName)
+		case models.IdentitySourceQuery:
+			value = r.URL.Query().Get(a.ParamName)
+		case models.IdentitySourceCookie:
+			if cookie, err := r.Cookie(a.ParamName); err == nil {
+				value = cookie.Value
+			}
+		}
+	}
+	if value == "" && info != nil {
+		value = info.RUID
+	}
+	return value
+}
+
+// hashPoint maps salt and identity onto a stable point in [0, 1)
+func hashPoint(salt, identity string) float64 {
+	sum := sha256.Sum256([]byte(salt + ":" + identity))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// thompsonDraws is the number of Monte Carlo draws used to estimate the
+// probability of each target being the best one
+const thompsonDraws = 10000
+
+// ThompsonWeights computes target weights proportional to the probability of
+// each target having the highest conversion rate, with successes and failures
+// modelled as Beta(1+conversions, 1+exposures-conversions). Every target keeps
+// at least minExploration of the traffic.
+func ThompsonWeights(counters []models.BanditCounters, minExploration float64, rng *rand.Rand) []models.BanditArm {
+	arms := make([]models.BanditArm, len(counters))
+	if len(counters) == 0 {
+		return arms
+	}
+
+	alphas := make([]float64, len(counters))
+	betas := make([]float64, len(counters))
+	for i, c := range counters {
+		failures := c.Exposures - c.Conversions
+		if failures < 0 {
+			failures = 0
+		}
+		alphas[i] = 1 + float64(c.Conversions)
+		betas[i] = 1 + float64(failures)
+		arms[i].BanditCounters = c
+	}
+
+	wins := make([]int, len(counters))
+	for d := 0; d < thompsonDraws; d++ {
+		best, bestSample := 0, -1.0
+		for i := range counters {
+			if sample := betaSample(rng, alphas[i], betas[i]); sample > bestSample {
+				best, bestSample = i, sample
+			}
+		}
+		wins[best]++
+	}
+
+	// Spread the exploration floor evenly and share the rest by probability
+	floor := math.Max(0, math.Min(minExploration, 1/float64(len(counters))))
+	remaining := 1 - floor*float64(len(counters))
+	for i := range arms {
+		arms[i].Probability = float64(wins[i]) / thompsonDraws
+		arms[i].Weight = floor + remaining*arms[i].Probability
+	}
+	return arms
+}
+
+// betaSample draws from Beta(a, b) using two gamma variates
+func betaSample(rng *rand.Rand, a, b float64) float64 {
+	x := gammaSample(rng, a)
+	y := gammaSample(rng, b)
+	return x / (x + y)
+}
+
+// gammaSample draws from Gamma(shape, 1) with the Marsaglia-Tsang method
+func gammaSample(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return gammaSample(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
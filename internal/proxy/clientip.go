@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parsePrefixes parses CIDRs and single IP addresses into prefixes
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", v, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q: %w", v, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ValidatePrefixes checks that every value is a valid CIDR or IP address
+func ValidatePrefixes(values []string) error {
+	_, err := parsePrefixes(values)
+	return err
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerAddr returns the address of the immediate peer of the connection
+func peerAddr(r *http.Request) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// isTrustedPeer reports whether the immediate peer is one of the trusted proxies
+func (p *Proxy) isTrustedPeer(r *http.Request) bool {
+	peer, ok := peerAddr(r)
+	return ok && containsAddr(p.trustedProxies, peer)
+}
+
+// clientIP resolves the real client IP. Forwarding headers are only honoured
+// when the immediate peer is a trusted proxy; X-Forwarded-For is walked from
+// the right, skipping trusted hops, so clients cannot spoof their address.
+func (p *Proxy) clientIP(r *http.Request) string {
+	peer, ok := peerAddr(r)
+	if !ok {
+		return ""
+	}
+	if !containsAddr(p.trustedProxies, peer) {
+		return peer.String()
+	}
+
+	if ip, ok := p.forwardedFor(r); ok {
+		return ip
+	}
+
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+
+	return peer.String()
+}
+
+// forwardedFor returns the rightmost untrusted hop of X-Forwarded-For, or the
+// leftmost hop if all of them are trusted. A hop that is not an IP address
+// makes the header unusable: the hops to its right are trusted proxies and
+// must not be taken for the client
+func (p *Proxy) forwardedFor(r *http.Request) (string, bool) {
+	xff := r.Header.Values("X-Forwarded-For")
+	if len(xff) == 0 {
+		return "", false
+	}
+
+	hops := strings.Split(strings.Join(xff, ","), ",")
+	var leftmost string
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return "", false
+		}
+		leftmost = addr.Unmap().String()
+		if !containsAddr(p.trustedProxies, addr) {
+			return leftmost, true
+		}
+	}
+	return leftmost, leftmost != ""
+}
+
+// forwardClientIP passes the resolved client IP to the upstream. Forwarding
+// headers from untrusted peers are dropped, the reverse proxy then appends
+// the peer address to X-Forwarded-For itself.
+func (p *Proxy) forwardClientIP(r *http.Request) {
+	if !p.isTrustedPeer(r) {
+		r.Header.Del("X-Forwarded-For")
+	}
+	if ip := p.clientIP(r); ip != "" {
+		r.Header.Set("X-Real-IP", ip)
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// consentPolicy is a compiled consent policy, a nil policy treats every user as consenting
+type consentPolicy struct {
+	source models.IdentitySource
+	name   string
+	values map[string]bool // nil grants consent for any non-empty value
+}
+
+// ValidateConsent checks the source and name of the consent signal
+func ValidateConsent(c *models.ConsentPolicy) error {
+	_, err := compileConsent(c)
+	return err
+}
+
+func compileConsent(c *models.ConsentPolicy) (*consentPolicy, error) {
+	if c == nil || !c.Enabled {
+		return nil, nil
+	}
+	if c.Source != models.IdentitySourceCookie && c.Source != models.IdentitySourceHeader {
+		return nil, fmt.Errorf("consent source must be cookie or header: %s", c.Source)
+	}
+	if !validHeaderName(c.Name) {
+		return nil, fmt.Errorf("invalid consent %s name: %q", c.Source, c.Name)
+	}
+
+	policy := &consentPolicy{source: c.Source, name: c.Name}
+	if len(c.Values) > 0 {
+		policy.values = make(map[string]bool, len(c.Values))
+		for _, value := range c.Values {
+			if value == "" {
+				return nil, fmt.Errorf("consent values must not be empty")
+			}
+			policy.values[value] = true
+		}
+	}
+	return policy, nil
+}
+
+// given reports whether the request carries the consent signal
+func (c *consentPolicy) given(r *http.Request) bool {
+	if c == nil {
+		return true
+	}
+
+	var value string
+	switch c.source {
+	case models.IdentitySourceCookie:
+		if cookie, err := r.Cookie(c.name); err == nil {
+			value = cookie.Value
+		}
+	case models.IdentitySourceHeader:
+		value = r.Header.Get(c.name)
+	}
+	if value == "" {
+		return false
+	}
+	return c.values == nil || c.values[value]
+}
@@ -1,60 +1,176 @@
 package proxy
 
 import (
+	"crypto/hmac"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
 )
 
+const defaultCookieTTL = 30 * 24 * time.Hour
+
+// cookiePolicy is the cookie policy of a proxy with defaults applied
+type cookiePolicy struct {
+	name     string
+	domain   string
+	path     string
+	ttl      time.Duration
+	secure   bool
+	sameSite http.SameSite
+}
+
+// ValidateCookiePolicy checks the cookie name, path, lifetime and SameSite mode
+func ValidateCookiePolicy(c *models.CookiePolicy) error {
+	_, err := compileCookiePolicy("", c)
+	return err
+}
+
+func compileCookiePolicy(proxyID string, c *models.CookiePolicy) (cookiePolicy, error) {
+	policy := cookiePolicy{
+		name:     fmt.Sprintf("proxy_%s", proxyID),
+		path:     "/",
+		ttl:      defaultCookieTTL,
+		sameSite: http.SameSiteLaxMode,
+	}
+	if c == nil {
+		return policy, nil
+	}
+
+	if c.Name != "" {
+		if !validHeaderName(c.Name) {
+			return policy, fmt.Errorf("invalid cookie name: %q", c.Name)
+		}
+		policy.name = c.Name
+	}
+	if c.Path != "" {
+		if !strings.HasPrefix(c.Path, "/") {
+			return policy, fmt.Errorf("cookie path must start with /: %s", c.Path)
+		}
+		policy.path = c.Path
+	}
+	if strings.ContainsAny(c.Domain, " ;,") {
+		return policy, fmt.Errorf("invalid cookie domain: %q", c.Domain)
+	}
+	if c.TTLSeconds < 0 {
+		return policy, fmt.Errorf("cookie ttl_seconds must not be negative")
+	}
+	if c.TTLSeconds > 0 {
+		policy.ttl = time.Duration(c.TTLSeconds) * time.Second
+	}
+
+	switch strings.ToLower(c.SameSite) {
+	case "", "lax":
+	case "strict":
+		policy.sameSite = http.SameSiteStrictMode
+	case "none":
+		// Browsers drop SameSite=None cookies that are not secure
+		if !c.Secure {
+			return policy, fmt.Errorf("cookie same_site none requires secure")
+		}
+		policy.sameSite = http.SameSiteNoneMode
+	default:
+		return policy, fmt.Errorf("invalid cookie same_site: %s", c.SameSite)
+	}
+	policy.domain = c.Domain
+	policy.secure = c.Secure
+	return policy, nil
+}
+
+// cookie returns a cookie with the domain, path and flags of the policy
+func (c cookiePolicy) cookie(name, value string, maxAge time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   c.domain,
+		Path:     c.path,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   c.secure,
+		HttpOnly: true,
+		SameSite: c.sameSite,
+	}
+}
+
+// signSticky creates the sticky cookie value "<target_id>.<signature>". It is signed
+// with the secret of the override tokens under its own prefix, so neither can be
+// used as the other
+func signSticky(secret []byte, proxyID, targetID string) string {
+	return targetID + "." + overrideSignature(string(secret), proxyID, "sticky:"+targetID)
+}
+
+// verifySticky returns the target ID of a sticky cookie value with a valid signature
+func verifySticky(secret []byte, proxyID, value string) (string, bool) {
+	if len(secret) == 0 {
+		return "", false
+	}
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return "", false
+	}
+	targetID, signature := value[:i], value[i+1:]
+	expected := overrideSignature(string(secret), proxyID, "sticky:"+targetID)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", false
+	}
+	return targetID, true
+}
+
+// secret returns the per-proxy signing secret, nil until it is loaded
+func (p *Proxy) secret() []byte {
+	if p.overrides == nil {
+		return nil
+	}
+	return p.overrides.secret
+}
+
+// getTargetFromCookie returns the target stored in the sticky cookie. Cookies
+// with an invalid signature or of a target whose weight was set to zero are
+// ignored, the user is assigned anew and gets a new cookie
 func (p *Proxy) getTargetFromCookie(r *http.Request) *Target {
-	cookie, err := r.Cookie(p.cookieName)
+	cookie, err := r.Cookie(p.cookie.name)
 	if err != nil {
 		return nil
 	}
+	targetID, ok := verifySticky(p.secret(), p.ID, cookie.Value)
+	if !ok {
+		return nil
+	}
 
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
-
-	for _, target := range p.Targets {
-		if target.IsActive && target.URL == cookie.Value {
-			return &target
+	targets := p.snapshot().targets
+	for i := range targets {
+		if !targets[i].IsActive || targets[i].ID != targetID {
+			continue
+		}
+		if targets[i].Weight <= 0 {
+			return nil
 		}
+		if p.available(targets[i]) {
+			return &targets[i]
+		}
+		// The sticky target is ejected or unhealthy
+		return p.stickyFallback()
 	}
 	return nil
 }
 
-func (p *Proxy) setCookies(w http.ResponseWriter, info *RedirectInfo) {
-	// Set ab_target cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "ab_target_",
-		Value:    p.Targets[0].URL,
-		Path:     "/",
-		MaxAge:   3600 * 24, // 24 hours
-		HttpOnly: true,
-	})
+// setStickyCookie keeps the user on the target on later requests
+func (p *Proxy) setStickyCookie(w http.ResponseWriter, target *Target) {
+	secret := p.secret()
+	if len(secret) == 0 {
+		return
+	}
+	http.SetCookie(w, p.cookie.cookie(p.cookie.name, signSticky(secret, p.ID, target.ID), p.cookie.ttl))
+}
 
+func (p *Proxy) setCookies(w http.ResponseWriter, info *RedirectInfo) {
 	// Set RID cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "rid",
-		Value:    info.RID,
-		Path:     "/",
-		MaxAge:   3600 * 24 * 30, // 30 days
-		HttpOnly: true,
-	})
+	http.SetCookie(w, p.cookie.cookie("rid", info.RID, 30*24*time.Hour))
 
 	// Set RRID cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "rrid",
-		Value:    info.RRID,
-		Path:     "/",
-		MaxAge:   3600 * 24, // 24 hours
-		HttpOnly: true,
-	})
+	http.SetCookie(w, p.cookie.cookie("rrid", info.RRID, 24*time.Hour))
 
 	// Set RUID cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "ruid",
-		Value:    info.RUID,
-		Path:     "/",
-		MaxAge:   3600 * 24 * 365, // 1 year
-		HttpOnly: true,
-	})
+	http.SetCookie(w, p.cookie.cookie("ruid", info.RUID, 365*24*time.Hour))
 }
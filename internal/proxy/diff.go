@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	defaultDiffMaxBody = 1 << 20
+	// maxFieldDiffs bounds the differing fields kept per example
+	maxFieldDiffs = 20
+)
+
+// differ compares primary and shadow responses
+type differ struct {
+	sampleRate float64
+	headers    []string
+	ignore     [][]string
+	maxBody    int64
+}
+
+// capturedResponse is a response kept for comparison
+type capturedResponse struct {
+	status    int
+	header    http.Header
+	body      []byte
+	truncated bool
+}
+
+func compileDiff(d *models.DiffSettings) (*differ, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if d.SampleRate <= 0 || d.SampleRate > 1 {
+		return nil, errors.New("diff sample_rate must be greater than 0 and at most 1")
+	}
+	if d.MaxBodyBytes < 0 {
+		return nil, errors.New("diff max_body_bytes must be non-negative")
+	}
+
+	compiled := &differ{
+		sampleRate: d.SampleRate,
+		maxBody:    d.MaxBodyBytes,
+	}
+	if compiled.maxBody == 0 {
+		compiled.maxBody = defaultDiffMaxBody
+	}
+	for _, h := range d.Headers {
+		compiled.headers = append(compiled.headers, http.CanonicalHeaderKey(h))
+	}
+	for _, field := range d.IgnoreFields {
+		if field == "" {
+			return nil, errors.New("diff ignore_fields must not be empty")
+		}
+		compiled.ignore = append(compiled.ignore, strings.Split(field, "."))
+	}
+	return compiled, nil
+}
+
+// captureWriter passes the primary response to the client and keeps a copy of it
+type captureWriter struct {
+	http.ResponseWriter
+	limit    int64
+	response capturedResponse
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64) *captureWriter {
+	return &captureWriter{ResponseWriter: w, limit: limit}
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.response.status == 0 {
+		w.response.status = status
+		w.response.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.response.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if room := w.limit - int64(len(w.response.body)); room < int64(len(b)) {
+		w.response.truncated = true
+		if room > 0 {
+			w.response.body = append(w.response.body, b[:room]...)
+		}
+	} else {
+		w.response.body = append(w.response.body, b...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of the client connection
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// readCaptured reads a shadow response body up to the limit
+func readCaptured(resp *http.Response, limit int64) *capturedResponse {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	captured := &capturedResponse{
+		status: resp.StatusCode,
+		header: resp.Header,
+		body:   body,
+	}
+	if int64(len(body)) > limit {
+		captured.body = body[:limit]
+		captured.truncated = true
+	}
+	return captured
+}
+
+// compare returns the differences between the responses and whether there are any
+func (d *differ) compare(primary, shadow *capturedResponse) (models.ResponseDiff, bool) {
+	diff := models.ResponseDiff{
+		PrimaryStatus: primary.status,
+		ShadowStatus:  shadow.status,
+	}
+	mismatch := primary.status != shadow.status
+
+	for _, h := range d.headers {
+		p, s := primary.header.Values(h), shadow.header.Values(h)
+		if !reflect.DeepEqual(p, s) {
+			diff.Headers = append(diff.Headers, models.FieldDiff{
+				Path:    h,
+				Primary: strings.Join(p, ", "),
+				Shadow:  strings.Join(s, ", "),
+			})
+		}
+	}
+	mismatch = mismatch || len(diff.Headers) > 0
+
+	// Truncated bodies cannot be compared reliably
+	if primary.truncated || shadow.truncated {
+		return diff, mismatch
+	}
+
+	// The shadow request carries the client's Accept-Encoding, compressed bodies
+	// are decoded so JSON is still compared field by field
+	primaryBody, pErr := d.decodeBody(primary)
+	shadowBody, sErr := d.decodeBody(shadow)
+	if errors.Is(pErr, errDecodedTooLarge) || errors.Is(sErr, errDecodedTooLarge) {
+		return diff, mismatch
+	}
+	if pErr != nil || sErr != nil {
+		primaryBody, shadowBody = primary.body, shadow.body
+	}
+
+	p, pErr := decodeJSON(primaryBody)
+	s, sErr := decodeJSON(shadowBody)
+	if pErr == nil && sErr == nil {
+		d.diffJSON(nil, p, s, &diff.Fields)
+		mismatch = mismatch || len(diff.Fields) > 0
+	} else if !bytes.Equal(primaryBody, shadowBody) {
+		diff.BodyDiffers = true
+		mismatch = true
+	}
+	return diff, mismatch
+}
+
+var errDecodedTooLarge = errors.New("decoded body exceeds the limit")
+
+// decodeBody returns the body decoded according to its Content-Encoding, up to
+// the body limit. Unsupported encodings are returned as an error
+func (d *differ) decodeBody(c *capturedResponse) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(c.header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return c.body, nil
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(c.body))
+	case "deflate":
+		reader, err = zlib.NewReader(bytes.NewReader(c.body))
+	default:
+		return nil, errors.New("unsupported content encoding")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(io.LimitReader(reader, d.maxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > d.maxBody {
+		return nil, errDecodedTooLarge
+	}
+	return body, nil
+}
+
+func decodeJSON(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after JSON value")
+	}
+	return v, nil
+}
+
+// diffJSON appends the paths where a and b differ, skipping ignored fields
+func (d *differ) diffJSON(path []string, a, b any, out *[]models.FieldDiff) {
+	if len(*out) >= maxFieldDiffs || d.ignored(path) {
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(av)+len(bv))
+			for k := range av {
+				keys = append(keys, k)
+			}
+			for k := range bv {
+				if _, ok := av[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				d.diffJSON(append(path[:len(path):len(path)], k), av[k], bv[k], out)
+			}
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			n := len(av)
+			if len(bv) > n {
+				n = len(bv)
+			}
+			for i := 0; i < n; i++ {
+				var ai, bi any
+				if i < len(av) {
+					ai = av[i]
+				}
+				if i < len(bv) {
+					bi = bv[i]
+				}
+				d.diffJSON(append(path[:len(path):len(path)], strconv.Itoa(i)), ai, bi, out)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*out = append(*out, models.FieldDiff{Path: strings.Join(path, "."), Primary: a, Shadow: b})
+	}
+}
+
+// ignored reports whether the path is inside one of the ignored fields
+func (d *differ) ignored(path []string) bool {
+	for _, pattern := range d.ignore {
+		if len(pattern) > len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range pattern {
+			if segment != "*" && segment != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDiff compares a shadow response with the primary one
+func (p *Proxy) recordDiff(shadow string, req *mirrorRequest, resp *capturedResponse, err error) {
+	var diff models.ResponseDiff
+	mismatch := true
+	if err != nil {
+		diff = models.ResponseDiff{PrimaryStatus: req.primary.status, Error: err.Error()}
+	} else {
+		diff, mismatch = p.mirror.differ.compare(req.primary, resp)
+	}
+
+	diff.Shadow = shadow
+	diff.Method = req.method
+	diff.Path = req.path
+	diff.CreatedAt = time.Now()
+	p.stats.RecordDiff(shadow, diff, mismatch)
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// LatencyBuckets are the upper bounds of the per-target latency histogram kept
+// for guardrails, the last bucket counts everything slower than the last bound
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	75 * time.Millisecond, 100 * time.Millisecond, 150 * time.Millisecond, 200 * time.Millisecond,
+	300 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond,
+	time.Second, 1500 * time.Millisecond, 2 * time.Second, 3 * time.Second,
+	5 * time.Second, 7500 * time.Millisecond, 10 * time.Second, 30 * time.Second,
+}
+
+func latencyBucket(d time.Duration) int {
+	return sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+}
+
+// LatencyPercentile estimates the q-th quantile of a histogram over LatencyBuckets,
+// interpolating linearly inside the bucket like Prometheus' histogram_quantile
+func LatencyPercentile(counts []int64, q float64) time.Duration {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+	var cumulative int64
+	for i, n := range counts {
+		if n == 0 || float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+		// Nothing is known about the overflow bucket beyond its lower bound
+		if i >= len(LatencyBuckets) {
+			return LatencyBuckets[len(LatencyBuckets)-1]
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = LatencyBuckets[i-1]
+		}
+		fraction := (rank - float64(cumulative)) / float64(n)
+		return lower + time.Duration(fraction*float64(LatencyBuckets[i]-lower))
+	}
+	return LatencyBuckets[len(LatencyBuckets)-1]
+}
+
+func ValidateGuardrails(g *models.GuardrailSettings) error {
+	if g == nil {
+		return nil
+	}
+	if g.MaxErrorRatio < 0 || g.MaxErrorRatio > 1 {
+		return errors.New("guardrails max_error_ratio must be between 0 and 1")
+	}
+	if g.MaxLatencyRatio < 0 {
+		return errors.New("guardrails max_latency_ratio must not be negative")
+	}
+	if g.MinRequests < 0 || g.WindowSeconds < 0 {
+		return errors.New("guardrails min_requests and window_seconds must not be negative")
+	}
+	return nil
+}
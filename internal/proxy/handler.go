@@ -1,8 +1,8 @@
 package proxy
 
 import (
+	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"strings"
 	"time"
@@ -13,51 +13,57 @@ import (
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
+	// Without consent no identifiers are read or set
+	anonymous := !p.consent.given(r)
+
 	// Check if this is a redirect request from another proxy
 	if r.Header.Get("X-Internal-Redirect") == "true" {
 		// Remove the header to prevent redirect loops
 		r.Header.Del("X-Internal-Redirect")
-		target, err := p.selectTarget(r)
+		redirectInfo, err := p.getOrCreateRedirectInfo(r, anonymous, true)
 		if err != nil {
-			http.Error(w, "Error selecting target", http.StatusInternalServerError)
-			p.stats.IncrementErrors(p.ID)
+			http.Error(w, "Failed to process redirect info", http.StatusInternalServerError)
 			return
 		}
-		targetURL, err := url.Parse(target.URL)
+		target, _, _, err := p.selectTarget(r, redirectInfo)
 		if err != nil {
-			http.Error(w, "Invalid target URL", http.StatusInternalServerError)
+			http.Error(w, "Error selecting target", http.StatusInternalServerError)
+			p.stats.IncrementErrors(p.ID)
 			return
 		}
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			p.stats.IncrementErrors(target.ID)
-			http.Error(w, "Error forwarding request", http.StatusBadGateway)
-		}
-		proxy.ServeHTTP(w, r)
+		stripOverride(r, redirectInfo)
+		p.forwardClientIP(r)
+		p.forward(w, r, redirectInfo, target, nil)
 		return
 	}
 
-	redirectInfo, err := p.getOrCreateRedirectInfo(r)
+	redirectInfo, err := p.getOrCreateRedirectInfo(r, anonymous, false)
 	if err != nil {
 		http.Error(w, "Failed to process redirect info", http.StatusInternalServerError)
 		return
 	}
 
-	target, err := p.selectTarget(r)
+	target, cohort, sticky, err := p.selectTarget(r, redirectInfo)
 	if err != nil {
 		http.Error(w, "Error selecting target", http.StatusInternalServerError)
 		p.stats.IncrementErrors(p.ID)
 		return
 	}
+	stripOverride(r, redirectInfo)
 
-	p.setCookies(w, redirectInfo)
+	if !anonymous {
+		p.setCookies(w, redirectInfo)
+		if sticky {
+			p.setStickyCookie(w, target)
+		}
+	}
 
 	// Track request
-	p.stats.IncrementRequests(target.ID)
+	p.stats.IncrementExposure(target.ID, cohort)
 
 	if p.Mode == models.ProxyModeRedirect {
 		// Check if the target URL has a different host
-		targetURL := p.appendRedirectParams(target.URL, redirectInfo)
+		targetURL := p.appendRedirectParams(target, r.URL.Path, redirectInfo)
 		parsedTarget, err := url.Parse(targetURL)
 		if err != nil {
 			http.Error(w, "Invalid target URL", http.StatusInternalServerError)
@@ -79,72 +85,114 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// For reverse proxy mode
-	targetURL, err := url.Parse(target.URL)
-	if err != nil {
-		http.Error(w, "Invalid target URL", http.StatusInternalServerError)
-		return
-	}
-
 	// Add redirect info to request headers
 	r.Header.Set("X-Redirect-ID", redirectInfo.RID)
 	r.Header.Set("X-Redirect-Request-ID", redirectInfo.RRID)
-	r.Header.Set("X-Redirect-User-ID", redirectInfo.RUID)
-	r.Header.Set("X-Redirect-Query-Params", targetURL.RawQuery)
-
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		p.stats.IncrementErrors(target.ID)
-		http.Error(w, "Error forwarding request", http.StatusBadGateway)
+	if !anonymous {
+		r.Header.Set("X-Redirect-User-ID", redirectInfo.RUID)
+	}
+	p.forwardClientIP(r)
+
+	// Buffer the body for retries and copy the request for shadow targets
+	// before the primary consumes the body
+	retry := p.prepareRetry(r, target)
+	shadowRequest := p.captureMirror(r)
+	w, captured := p.captureResponse(w, shadowRequest)
+
+	target, status := p.forward(w, r, redirectInfo, target, retry)
+	captured()
+	p.sendMirror(shadowRequest)
+
+	elapsed := time.Since(start)
+	p.observeLatency(target.ID, elapsed)
+	if status != 0 {
+		p.stats.RecordResponse(target.ID, status, elapsed)
 	}
 
-	proxy.ServeHTTP(w, r)
-
-	duration := time.Since(start).Seconds()
+	duration := elapsed.Seconds()
 	p.metrics.LatencyHistogram.WithLabelValues(target.URL).Observe(duration)
 	p.metrics.RequestsTotal.WithLabelValues(target.URL).Inc()
 }
 
 func (p *Proxy) getTargetByCondition(r *http.Request) *Target {
-	var value string
-	switch p.Config.Condition.Type {
+	rt := p.snapshot()
+
+	// Rule trees are evaluated in order, the first match wins
+	for _, route := range rt.routes {
+		if route.rule.matches(p, r) {
+			if target := p.getTargetById(route.targetID); target != nil {
+				return target
+			}
+		}
+	}
+
+	if !p.Config.Condition.Type.IsValid() {
+		return p.getTargetById(p.Config.Condition.Default)
+	}
+	value := p.requestValue(r, p.Config.Condition.Type, p.Config.Condition.ParamName)
+
+	// Check if the value matches the value configured for any of the targets
+	for _, m := range rt.matchers {
+		if m.match(value) {
+			if target := p.getTargetById(m.targetID); target != nil {
+				return target
+			}
+		}
+	}
+
+	// Fall back to default target
+	return p.getTargetById(p.Config.Condition.Default)
+}
+
+// requestValue extracts the value of the given condition source from the request
+func (p *Proxy) requestValue(r *http.Request, conditionType models.ConditionType, paramName string) string {
+	switch conditionType {
 	case models.ConditionTypeHeader:
-		value = r.Header.Get(p.Config.Condition.ParamName)
+		return r.Header.Get(paramName)
 	case models.ConditionTypeQuery:
-		value = r.URL.Query().Get(p.Config.Condition.ParamName)
+		return r.URL.Query().Get(paramName)
 	case models.ConditionTypeCookie:
-		cookie, err := r.Cookie(p.Config.Condition.ParamName)
-		if err == nil {
-			value = cookie.Value
+		if cookie, err := r.Cookie(paramName); err == nil {
+			return cookie.Value
 		}
 	case models.ConditionTypeUserAgent:
 		ua := r.Header.Get("User-Agent")
-		switch p.Config.Condition.ParamName {
+		switch paramName {
 		case "platform":
-			value = detectPlatform(ua)
+			return detectPlatform(ua)
 		case "browser":
-			value = detectBrowser(ua)
+			return detectBrowser(ua)
 		}
 	case models.ConditionTypeLanguage:
-		value = parseAcceptLanguage(r.Header.Get("Accept-Language"))
-	default:
-		return p.getTargetById(p.Config.Condition.Default)
+		return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	case models.ConditionTypePath:
+		return r.URL.Path
+	case models.ConditionTypeMethod:
+		return strings.ToUpper(r.Method)
+	case models.ConditionTypeHost:
+		return requestHost(r)
+	case models.ConditionTypeIP:
+		return p.clientIP(r)
+	case models.ConditionTypeGeo:
+		return strings.ToUpper(p.geo.Lookup(p.clientIP(r)).Field(paramName))
 	}
+	return ""
+}
 
-	// Check if the value matches any of the specified values
-	if targetID, ok := p.Config.Condition.Values[value]; ok {
-		if target := p.getTargetById(targetID); target != nil {
-			return target
-		}
+// requestHost returns the lower-cased request host without the port
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
 	}
-
-	// Fall back to default target
-	return p.getTargetById(p.Config.Condition.Default)
+	return strings.ToLower(host)
 }
 
 func (p *Proxy) getTargetById(id string) *Target {
-	for _, target := range p.Targets {
-		if target.ID == id && target.IsActive {
-			return &target
+	targets := p.snapshot().targets
+	for i := range targets {
+		if targets[i].ID == id && p.available(targets[i]) {
+			return &targets[i]
 		}
 	}
 	return nil
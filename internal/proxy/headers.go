@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// headerRules are compiled header rewrite rules, nil rules change nothing
+type headerRules struct {
+	request  []headerRule
+	response []headerRule
+}
+
+type headerRule struct {
+	action    models.HeaderAction
+	name      string // Canonical header name
+	value     string
+	templated bool
+}
+
+// headerVars are the values placeholders in header rule values are replaced with
+type headerVars struct {
+	ruid, rrid, rid, targetID string
+}
+
+var headerPlaceholders = []string{"{ruid}", "{rrid}", "{rid}", "{target_id}"}
+
+// ValidateHeaderRules checks the actions, names and values of header rules
+func ValidateHeaderRules(h *models.HeaderRules) error {
+	_, err := compileHeaderRules(h)
+	return err
+}
+
+func compileHeaderRules(h *models.HeaderRules) (*headerRules, error) {
+	if h == nil || (len(h.Request) == 0 && len(h.Response) == 0) {
+		return nil, nil
+	}
+
+	var err error
+	compiled := &headerRules{}
+	if compiled.request, err = compileHeaderRuleList(h.Request, true); err != nil {
+		return nil, fmt.Errorf("invalid request header rule: %w", err)
+	}
+	if compiled.response, err = compileHeaderRuleList(h.Response, false); err != nil {
+		return nil, fmt.Errorf("invalid response header rule: %w", err)
+	}
+	return compiled, nil
+}
+
+func compileHeaderRuleList(rules []models.HeaderRule, request bool) ([]headerRule, error) {
+	compiled := make([]headerRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Action.IsValid() {
+			return nil, fmt.Errorf("unknown action: %s", rule.Action)
+		}
+		if !validHeaderName(rule.Name) {
+			return nil, fmt.Errorf("invalid header name: %q", rule.Name)
+		}
+		if strings.ContainsAny(rule.Value, "\r\n\x00") {
+			return nil, fmt.Errorf("invalid value for header %s", rule.Name)
+		}
+
+		name := http.CanonicalHeaderKey(rule.Name)
+		if request && name == "Host" && rule.Action != models.HeaderActionSet {
+			return nil, fmt.Errorf("the Host header can only be set")
+		}
+
+		templated := false
+		for _, placeholder := range headerPlaceholders {
+			if strings.Contains(rule.Value, placeholder) {
+				templated = true
+				break
+			}
+		}
+		compiled = append(compiled, headerRule{
+			action:    rule.Action,
+			name:      name,
+			value:     rule.Value,
+			templated: templated,
+		})
+	}
+	return compiled, nil
+}
+
+// validHeaderName reports whether name is a non-empty HTTP token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (v headerVars) expand(value string) string {
+	return strings.NewReplacer(
+		"{ruid}", v.ruid,
+		"{rrid}", v.rrid,
+		"{rid}", v.rid,
+		"{target_id}", v.targetID,
+	).Replace(value)
+}
+
+func (h *headerRules) applyRequest(r *http.Request, vars headerVars) {
+	if h == nil {
+		return
+	}
+	for _, rule := range h.request {
+		if rule.name == "Host" {
+			r.Host = rule.valueFor(vars)
+			continue
+		}
+		rule.apply(r.Header, vars)
+	}
+}
+
+func (h *headerRules) applyResponse(resp *http.Response, vars headerVars) {
+	if h == nil {
+		return
+	}
+	for _, rule := range h.response {
+		rule.apply(resp.Header, vars)
+	}
+}
+
+func (r headerRule) apply(header http.Header, vars headerVars) {
+	switch r.action {
+	case models.HeaderActionSet:
+		header.Set(r.name, r.valueFor(vars))
+	case models.HeaderActionAdd:
+		header.Add(r.name, r.valueFor(vars))
+	case models.HeaderActionRemove:
+		header.Del(r.name)
+	}
+}
+
+func (r headerRule) valueFor(vars headerVars) string {
+	if !r.templated {
+		return r.value
+	}
+	return vars.expand(r.value)
+}
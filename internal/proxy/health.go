@@ -0,0 +1,319 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	defaultHealthInterval           = 10 * time.Second
+	defaultHealthTimeout            = 2 * time.Second
+	defaultHealthyThreshold         = 2
+	defaultUnhealthyThreshold       = 3
+	maxHealthResponseBody     int64 = 64 << 10
+)
+
+// HealthChecker probes targets that have an active health check and keeps their
+// state. It outlives proxies, which are rebuilt on every settings change, so a
+// target stays unhealthy until it recovers
+type HealthChecker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	client *http.Client
+
+	mu     sync.RWMutex
+	checks map[string]*healthCheck // key is target ID
+}
+
+type healthCheck struct {
+	proxyID   string
+	targetURL string
+	config    models.HealthCheck // As configured, to detect changes
+	settings  models.HealthCheck // With defaults applied
+	probeURL  string
+	stop      context.CancelFunc
+	healthy   atomic.Bool
+
+	mu    sync.Mutex
+	state models.TargetHealth
+}
+
+func NewHealthChecker() *HealthChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HealthChecker{
+		ctx:    ctx,
+		cancel: cancel,
+		client: &http.Client{
+			// A redirect is an answer, it is not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		checks: make(map[string]*healthCheck),
+	}
+}
+
+// Healthy reports whether a target may get new users. Targets without a
+// health check are always healthy, h may be nil
+func (h *HealthChecker) Healthy(targetID string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.RLock()
+	check := h.checks[targetID]
+	h.mu.RUnlock()
+	return check == nil || check.healthy.Load()
+}
+
+// Sync starts the health checks of a proxy's active targets and stops the ones
+// of targets that were removed, deactivated or had their check changed
+func (h *HealthChecker) Sync(proxyID string, targets []Target) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	wanted := make(map[string]Target)
+	for _, target := range targets {
+		if target.IsActive && target.HealthCheck != nil {
+			wanted[target.ID] = target
+		}
+	}
+
+	for id, check := range h.checks {
+		if check.proxyID != proxyID {
+			continue
+		}
+		if target, ok := wanted[id]; ok && target.URL == check.targetURL && *target.HealthCheck == check.config {
+			delete(wanted, id)
+			continue
+		}
+		check.stop()
+		delete(h.checks, id)
+		targetHealthy.DeleteLabelValues(proxyID, check.targetURL)
+	}
+
+	for _, target := range wanted {
+		h.start(proxyID, target)
+	}
+}
+
+// Remove stops the health checks of a deleted proxy
+func (h *HealthChecker) Remove(proxyID string) {
+	h.Sync(proxyID, nil)
+}
+
+// Stop stops all health checks
+func (h *HealthChecker) Stop() {
+	if h != nil {
+		h.cancel()
+	}
+}
+
+// Status returns the health of the checked targets of a proxy
+func (h *HealthChecker) Status(proxyID string) []models.TargetHealth {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	statuses := []models.TargetHealth{}
+	for _, check := range h.checks {
+		if check.proxyID != proxyID {
+			continue
+		}
+		check.mu.Lock()
+		statuses = append(statuses, check.state)
+		check.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
+
+func (h *HealthChecker) start(proxyID string, target Target) {
+	settings := *target.HealthCheck
+	if settings.IntervalSeconds == 0 {
+		settings.IntervalSeconds = int(defaultHealthInterval / time.Second)
+	}
+	if settings.TimeoutMs == 0 {
+		settings.TimeoutMs = int(defaultHealthTimeout / time.Millisecond)
+	}
+	if settings.HealthyThreshold == 0 {
+		settings.HealthyThreshold = defaultHealthyThreshold
+	}
+	if settings.UnhealthyThreshold == 0 {
+		settings.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+
+	probeURL, err := healthProbeURL(target.URL, settings.Path)
+	if err != nil {
+		log.Printf("Invalid health check for target %s of proxy %s: %v", target.ID, proxyID, err)
+		return
+	}
+
+	ctx, stop := context.WithCancel(h.ctx)
+	check := &healthCheck{
+		proxyID:   proxyID,
+		targetURL: target.URL,
+		config:    *target.HealthCheck,
+		settings:  settings,
+		probeURL:  probeURL,
+		stop:      stop,
+		state: models.TargetHealth{
+			TargetID: target.ID,
+			URL:      target.URL,
+			Healthy:  true,
+		},
+	}
+	// Targets are healthy until proven otherwise
+	check.healthy.Store(true)
+	targetHealthy.WithLabelValues(proxyID, target.URL).Set(1)
+
+	h.checks[target.ID] = check
+	go h.run(ctx, check)
+}
+
+func (h *HealthChecker) run(ctx context.Context, check *healthCheck) {
+	ticker := time.NewTicker(time.Duration(check.settings.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		h.probe(ctx, check)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthChecker) probe(ctx context.Context, check *healthCheck) {
+	probeCtx, cancel := context.WithTimeout(ctx, time.Duration(check.settings.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	status := 0
+	req, err := http.NewRequestWithContext(probeCtx, http.MethodGet, check.probeURL, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", "ab-testing-service-health-check")
+		var resp *http.Response
+		if resp, err = h.client.Do(req); err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxHealthResponseBody))
+			resp.Body.Close()
+			status = resp.StatusCode
+			if !check.expected(status) {
+				err = fmt.Errorf("unexpected status %d", status)
+			}
+		}
+	}
+
+	// The check was stopped while probing
+	if ctx.Err() != nil {
+		return
+	}
+	check.record(status, err)
+}
+
+func (c *healthCheck) expected(status int) bool {
+	if c.settings.ExpectedStatus != 0 {
+		return status == c.settings.ExpectedStatus
+	}
+	return status >= 200 && status < 300
+}
+
+// record updates the state with the outcome of a probe, the target changes
+// state after the configured number of consecutive successes or failures
+func (c *healthCheck) record(status int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.state.LastCheckedAt = &now
+	c.state.LastStatus = status
+	if err != nil {
+		c.state.LastError = err.Error()
+		c.state.ConsecutiveFailures++
+		c.state.ConsecutiveSuccesses = 0
+	} else {
+		c.state.LastError = ""
+		c.state.ConsecutiveSuccesses++
+		c.state.ConsecutiveFailures = 0
+	}
+
+	switch {
+	case c.state.Healthy && c.state.ConsecutiveFailures >= c.settings.UnhealthyThreshold:
+		c.state.Healthy = false
+		log.Printf("Target %s of proxy %s is unhealthy: %v", c.state.TargetID, c.proxyID, err)
+	case !c.state.Healthy && c.state.ConsecutiveSuccesses >= c.settings.HealthyThreshold:
+		c.state.Healthy = true
+		log.Printf("Target %s of proxy %s is healthy again", c.state.TargetID, c.proxyID)
+	default:
+		return
+	}
+
+	c.healthy.Store(c.state.Healthy)
+	value := 0.0
+	if c.state.Healthy {
+		value = 1
+	}
+	targetHealthy.WithLabelValues(c.proxyID, c.targetURL).Set(value)
+}
+
+// healthProbeURL builds the probe URL from the target's scheme and host and the check's path
+func healthProbeURL(targetURL, path string) (string, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = "/"
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	u.Path, u.RawPath, u.RawQuery, u.Fragment = ref.Path, ref.RawPath, ref.RawQuery, ""
+	return u.String(), nil
+}
+
+func ValidateHealthCheck(hc *models.HealthCheck) error {
+	if hc == nil {
+		return nil
+	}
+	if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+		return errors.New("health check path must start with /")
+	}
+	if _, err := url.Parse(hc.Path); err != nil {
+		return fmt.Errorf("invalid health check path: %w", err)
+	}
+	if hc.IntervalSeconds < 0 || hc.TimeoutMs < 0 || hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+		return errors.New("health check interval, timeout and thresholds must not be negative")
+	}
+	if hc.ExpectedStatus != 0 && (hc.ExpectedStatus < 100 || hc.ExpectedStatus > 599) {
+		return errors.New("health check expected_status must be a valid HTTP status code")
+	}
+	return nil
+}
+
+// SetHealthChecker sets the health checker whose unhealthy targets are left out of the selection
+func (p *Proxy) SetHealthChecker(health *HealthChecker) {
+	p.health = health
+}
+
+// available reports whether a target may get new users
+func (p *Proxy) available(target Target) bool {
+	return target.IsActive && p.health.Healthy(target.ID) && !p.outliers.Ejected(target.ID)
+}
@@ -0,0 +1,39 @@
+package proxy
+
+// Holdout is the service-wide group of users who never see any variant.
+// Membership depends only on the user identity and the salt, so it is the same
+// for every proxy and every service instance. It is keyed by the RUID, which is
+// the same on every proxy, not by the identity a proxy buckets users by.
+type Holdout struct {
+	percentage float64
+	salt       string
+}
+
+// NewHoldout creates a holdout of the given percentage of users, from 0 to 100
+func NewHoldout(percentage float64, salt string) *Holdout {
+	if salt == "" {
+		salt = "holdout"
+	}
+	return &Holdout{percentage: percentage, salt: salt}
+}
+
+// Percentage returns the share of users in the holdout, 0 when h is nil
+func (h *Holdout) Percentage() float64 {
+	if h == nil {
+		return 0
+	}
+	return h.percentage
+}
+
+// Contains reports whether the user with the given RUID is in the holdout
+func (h *Holdout) Contains(ruid string) bool {
+	if h == nil || h.percentage <= 0 || ruid == "" {
+		return false
+	}
+	return hashPoint(h.salt, ruid)*100 < h.percentage
+}
+
+// SetHoldout sets the global holdout, users in it always get the control target
+func (p *Proxy) SetHoldout(holdout *Holdout) {
+	p.holdout = holdout
+}
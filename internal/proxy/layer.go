@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// inLayer reports whether the user falls into the proxy's bucket range of its layer.
+// Buckets are salted with the layer ID only, so every proxy of a layer places a user
+// in the same bucket as long as they share the identity source.
+func (p *Proxy) inLayer(r *http.Request, info *RedirectInfo) bool {
+	layer := p.Config.Layer
+	if layer == nil || layer.LayerID == "" {
+		return true
+	}
+	bucket := int(hashPoint(layer.LayerID, p.identity(r, info)) * models.LayerBuckets)
+	return bucket >= layer.BucketStart && bucket < layer.BucketEnd
+}
+
+// controlTarget returns the active target marked as control, or the default target
+func (p *Proxy) controlTarget() *Target {
+	targets := p.snapshot().targets
+	for i := range targets {
+		if targets[i].IsControl && p.available(targets[i]) {
+			return &targets[i]
+		}
+	}
+	return p.defaultTarget()
+}
@@ -7,72 +7,120 @@ import (
 
 type Metrics struct {
 	RequestsTotal       *prometheus.CounterVec
-	LatencyHistogram    *prometheus.HistogramVec
+	LatencyHistogram    prometheus.ObserverVec
 	BytesSentTotal      *prometheus.CounterVec
 	BytesReceivedTotal  *prometheus.CounterVec
 	ResponseStatusTotal *prometheus.CounterVec
 	ActiveConnections   *prometheus.GaugeVec
 	RequestErrors       *prometheus.CounterVec
+	ShadowRequests      *prometheus.CounterVec
+	ShadowLatency       prometheus.ObserverVec
+	Retries             *prometheus.CounterVec
 }
 
+// Collectors are registered once and shared by all proxies, a proxy is
+// recreated on every settings change and registering them again would fail
+var (
+	requestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_requests_total",
+			Help: "Total number of requests per target",
+		},
+		[]string{"proxy_id", "target"},
+	)
+	latencyHistogram = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ab_test_request_duration_seconds",
+			Help:    "Request duration in seconds",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"proxy_id", "target"},
+	)
+	bytesSentTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_bytes_sent_total",
+			Help: "Total number of bytes sent to clients",
+		},
+		[]string{"proxy_id", "target"},
+	)
+	bytesReceivedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_bytes_received_total",
+			Help: "Total number of bytes received from targets",
+		},
+		[]string{"proxy_id", "target"},
+	)
+	responseStatusTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_response_status_total",
+			Help: "Total number of responses by status code",
+		},
+		[]string{"proxy_id", "target", "status"},
+	)
+	activeConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ab_test_active_connections",
+			Help: "Number of active connections",
+		},
+		[]string{"proxy_id", "target"},
+	)
+	requestErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_request_errors_total",
+			Help: "Total number of request errors",
+		},
+		[]string{"proxy_id", "target", "error_type"},
+	)
+	shadowRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_shadow_requests_total",
+			Help: "Total number of mirrored requests per shadow target and status code",
+		},
+		[]string{"proxy_id", "shadow", "status"},
+	)
+	targetHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ab_test_target_healthy",
+			Help: "Whether a target passes its active health check (1) or not (0)",
+		},
+		[]string{"proxy_id", "target"},
+	)
+	targetEjections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_target_ejections_total",
+			Help: "Total number of times a target was ejected by outlier detection",
+		},
+		[]string{"proxy_id", "target_id"},
+	)
+	retries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ab_test_retries_total",
+			Help: "Total number of retry decisions after failed upstream requests by outcome",
+		},
+		[]string{"proxy_id", "target", "outcome"},
+	)
+	shadowLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ab_test_shadow_request_duration_seconds",
+			Help:    "Mirrored request duration in seconds",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"proxy_id", "shadow"},
+	)
+)
+
 func newProxyMetrics(proxyID string) *Metrics {
+	labels := prometheus.Labels{"proxy_id": proxyID}
 	return &Metrics{
-		RequestsTotal: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name:        "ab_test_requests_total",
-				Help:        "Total number of requests per target",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-			},
-			[]string{"target"},
-		),
-		LatencyHistogram: promauto.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:        "ab_test_request_duration_seconds",
-				Help:        "Request duration in seconds",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-				Buckets:     []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
-			},
-			[]string{"target"},
-		),
-		BytesSentTotal: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name:        "ab_test_bytes_sent_total",
-				Help:        "Total number of bytes sent to clients",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-			},
-			[]string{"target"},
-		),
-		BytesReceivedTotal: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name:        "ab_test_bytes_received_total",
-				Help:        "Total number of bytes received from targets",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-			},
-			[]string{"target"},
-		),
-		ResponseStatusTotal: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name:        "ab_test_response_status_total",
-				Help:        "Total number of responses by status code",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-			},
-			[]string{"target", "status"},
-		),
-		ActiveConnections: promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name:        "ab_test_active_connections",
-				Help:        "Number of active connections",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-			},
-			[]string{"target"},
-		),
-		RequestErrors: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name:        "ab_test_request_errors_total",
-				Help:        "Total number of request errors",
-				ConstLabels: prometheus.Labels{"proxy_id": proxyID},
-			},
-			[]string{"target", "error_type"},
-		),
+		RequestsTotal:       requestsTotal.MustCurryWith(labels),
+		LatencyHistogram:    latencyHistogram.MustCurryWith(labels),
+		BytesSentTotal:      bytesSentTotal.MustCurryWith(labels),
+		BytesReceivedTotal:  bytesReceivedTotal.MustCurryWith(labels),
+		ResponseStatusTotal: responseStatusTotal.MustCurryWith(labels),
+		ActiveConnections:   activeConnections.MustCurryWith(labels),
+		RequestErrors:       requestErrors.MustCurryWith(labels),
+		ShadowRequests:      shadowRequests.MustCurryWith(labels),
+		ShadowLatency:       shadowLatency.MustCurryWith(labels),
+		Retries:             retries.MustCurryWith(labels),
 	}
 }
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	defaultMirrorMaxBody = 1 << 20
+	defaultMirrorTimeout = 5 * time.Second
+	// mirrorConcurrency bounds the shadow requests in flight per proxy,
+	// requests beyond it are not mirrored so shadows cannot pile up
+	mirrorConcurrency = 64
+	// ShadowHeader marks requests sent to shadow targets
+	ShadowHeader = "X-AB-Shadow"
+)
+
+// hopHeaders are connection specific and not copied to shadow requests
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+type mirror struct {
+	targets    []*url.URL
+	sampleRate float64
+	maxBody    int64
+	client     *http.Client
+	inflight   chan struct{}
+	differ     *differ
+}
+
+// mirrorRequest is a copy of a request taken before it is proxied
+type mirrorRequest struct {
+	method string
+	path   string
+	query  string
+	header http.Header
+	body   []byte
+
+	compare bool              // Compare shadow responses with the primary response
+	primary *capturedResponse // Set once the primary response has been served
+}
+
+// ValidateMirror checks the shadow target URLs and limits
+func ValidateMirror(m *models.MirrorSettings) error {
+	_, err := compileMirror(m)
+	return err
+}
+
+func compileMirror(m *models.MirrorSettings) (*mirror, error) {
+	if m == nil || len(m.Targets) == 0 {
+		return nil, nil
+	}
+	// A zero rate would mirror nothing, unlike the other limits it has no default
+	if m.SampleRate <= 0 || m.SampleRate > 1 {
+		return nil, errors.New("mirror sample_rate must be greater than 0 and at most 1")
+	}
+	if m.MaxBodyBytes < 0 || m.TimeoutMs < 0 {
+		return nil, errors.New("mirror limits must be non-negative")
+	}
+
+	var err error
+	compiled := &mirror{
+		sampleRate: m.SampleRate,
+		maxBody:    m.MaxBodyBytes,
+		client:     &http.Client{Timeout: defaultMirrorTimeout},
+		inflight:   make(chan struct{}, mirrorConcurrency),
+	}
+	if compiled.maxBody == 0 {
+		compiled.maxBody = defaultMirrorMaxBody
+	}
+	if m.TimeoutMs > 0 {
+		compiled.client.Timeout = time.Duration(m.TimeoutMs) * time.Millisecond
+	}
+	// Shadow responses are discarded, redirects are not followed
+	compiled.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	if compiled.differ, err = compileDiff(m.Diff); err != nil {
+		return nil, err
+	}
+
+	for _, raw := range m.Targets {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid mirror target: %s", raw)
+		}
+		compiled.targets = append(compiled.targets, u)
+	}
+	return compiled, nil
+}
+
+// captureMirror samples the request and copies it for the shadow targets, the body is
+// buffered up to the limit and restored for the primary target
+func (p *Proxy) captureMirror(r *http.Request) *mirrorRequest {
+	m := p.mirror
+	if m == nil || rand.Float64() >= m.sampleRate {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > m.maxBody {
+			return nil
+		}
+		buf, err := io.ReadAll(io.LimitReader(r.Body, m.maxBody+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		if err != nil || int64(len(buf)) > m.maxBody {
+			return nil
+		}
+		body = buf
+	}
+
+	header := r.Header.Clone()
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+	header.Set(ShadowHeader, "true")
+
+	return &mirrorRequest{
+		method:  r.Method,
+		path:    r.URL.Path,
+		query:   r.URL.RawQuery,
+		header:  header,
+		body:    body,
+		compare: m.differ != nil && rand.Float64() < m.differ.sampleRate,
+	}
+}
+
+// captureResponse wraps w to keep the primary response if the request is compared
+func (p *Proxy) captureResponse(w http.ResponseWriter, req *mirrorRequest) (http.ResponseWriter, func()) {
+	if req == nil || !req.compare {
+		return w, func() {}
+	}
+	capture := newCaptureWriter(w, p.mirror.differ.maxBody)
+	return capture, func() {
+		req.primary = &capture.response
+	}
+}
+
+// sendMirror replays the request to every shadow target in the background
+func (p *Proxy) sendMirror(req *mirrorRequest) {
+	if req == nil {
+		return
+	}
+	for _, target := range p.mirror.targets {
+		select {
+		case p.mirror.inflight <- struct{}{}:
+		default:
+			continue // Too many shadow requests in flight
+		}
+		go func(target *url.URL) {
+			defer func() { <-p.mirror.inflight }()
+			p.shadow(target, req)
+		}(target)
+	}
+}
+
+// shadow sends one mirrored request and records its outcome
+func (p *Proxy) shadow(target *url.URL, req *mirrorRequest) {
+	u := *target
+	u.Path = joinURLPath(target.Path, req.path)
+	u.RawQuery = joinQuery(target.RawQuery, req.query)
+
+	out, err := http.NewRequestWithContext(context.Background(), req.method, u.String(), bytes.NewReader(req.body))
+	if err != nil {
+		return
+	}
+	out.Header = req.header.Clone()
+
+	start := time.Now()
+	resp, err := p.mirror.client.Do(out)
+	latency := time.Since(start)
+
+	shadow := target.String()
+	status := 0
+	if err == nil {
+		if req.primary != nil {
+			p.recordDiff(shadow, req, readCaptured(resp, p.mirror.differ.maxBody), nil)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		status = resp.StatusCode
+	} else if req.primary != nil {
+		p.recordDiff(shadow, req, nil, err)
+	}
+
+	statusLabel := "error"
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	p.stats.RecordShadow(shadow, status, latency)
+	p.metrics.ShadowRequests.WithLabelValues(shadow, statusLabel).Inc()
+	p.metrics.ShadowLatency.WithLabelValues(shadow).Observe(latency.Seconds())
+}
+
+func joinQuery(base, query string) string {
+	if base == "" || query == "" {
+		return base + query
+	}
+	return base + "&" + query
+}
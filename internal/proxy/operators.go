@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// valueMatcher reports whether an extracted request value satisfies a compiled operator
+type valueMatcher func(value string) bool
+
+// compileMatcher precompiles the operator and its values into a matcher
+func compileMatcher(op models.MatchOperator, values []string) (valueMatcher, error) {
+	switch op {
+	case "", models.MatchOperatorEquals, models.MatchOperatorIn:
+		set := make(map[string]struct{}, len(values))
+		for _, v := range values {
+			set[v] = struct{}{}
+		}
+		return func(value string) bool {
+			_, ok := set[value]
+			return ok
+		}, nil
+	case models.MatchOperatorRegex:
+		patterns := make([]*regexp.Regexp, len(values))
+		for i, v := range values {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", v, err)
+			}
+			patterns[i] = re
+		}
+		return func(value string) bool {
+			for _, re := range patterns {
+				if re.MatchString(value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case models.MatchOperatorGlob:
+		return compileGlob(values)
+	case models.MatchOperatorCIDR:
+		prefixes, err := parsePrefixes(values)
+		if err != nil {
+			return nil, err
+		}
+		return func(value string) bool {
+			addr, err := netip.ParseAddr(value)
+			return err == nil && containsAddr(prefixes, addr)
+		}, nil
+	case models.MatchOperatorPrefix:
+		return anyValue(values, strings.HasPrefix), nil
+	case models.MatchOperatorSuffix:
+		return anyValue(values, strings.HasSuffix), nil
+	case models.MatchOperatorContains:
+		return anyValue(values, strings.Contains), nil
+	case models.MatchOperatorGT, models.MatchOperatorGTE, models.MatchOperatorLT, models.MatchOperatorLTE:
+		return compileNumeric(op, values)
+	case models.MatchOperatorVersion:
+		return compileVersion(values)
+	}
+	return nil, fmt.Errorf("unknown operator: %s", op)
+}
+
+// compileGlob turns path globs into anchored regular expressions: "**" matches
+// any characters, "*" any characters within a single path segment and "?" a
+// single character, so "/checkout/*" matches "/checkout/cart" but not
+// "/checkout/cart/items", which needs "/checkout/**"
+func compileGlob(values []string) (valueMatcher, error) {
+	patterns := make([]*regexp.Regexp, len(values))
+	for i, v := range values {
+		var b strings.Builder
+		b.WriteString("^")
+		for j := 0; j < len(v); j++ {
+			switch {
+			case strings.HasPrefix(v[j:], "**"):
+				b.WriteString(".*")
+				j++
+			case v[j] == '*':
+				b.WriteString("[^/]*")
+			case v[j] == '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(v[j : j+1]))
+			}
+		}
+		b.WriteString("$")
+
+		re, err := regexp.Compile(b.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", v, err)
+		}
+		patterns[i] = re
+	}
+
+	return func(value string) bool {
+		for _, re := range patterns {
+			if re.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func anyValue(values []string, fn func(s, substr string) bool) valueMatcher {
+	return func(value string) bool {
+		for _, v := range values {
+			if fn(value, v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func compileNumeric(op models.MatchOperator, values []string) (valueMatcher, error) {
+	if len(values) != 1 {
+		return nil, fmt.Errorf("operator %s requires exactly one value", op)
+	}
+	bound, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q for operator %s", values[0], op)
+	}
+
+	return func(value string) bool {
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return false
+		}
+		switch op {
+		case models.MatchOperatorGT:
+			return n > bound
+		case models.MatchOperatorGTE:
+			return n >= bound
+		case models.MatchOperatorLT:
+			return n < bound
+		default:
+			return n <= bound
+		}
+	}, nil
+}
+
+type versionConstraint struct {
+	op      string
+	version []int
+}
+
+// compileVersion parses version ranges; a range is a space separated list of
+// constraints that must all hold, e.g. ">=3.2.0 <4.0.0"
+func compileVersion(values []string) (valueMatcher, error) {
+	ranges := make([][]versionConstraint, 0, len(values))
+	for _, v := range values {
+		var constraints []versionConstraint
+		for _, field := range strings.Fields(v) {
+			c, err := parseVersionConstraint(field)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, c)
+		}
+		if len(constraints) == 0 {
+			return nil, fmt.Errorf("empty version range")
+		}
+		ranges = append(ranges, constraints)
+	}
+
+	return func(value string) bool {
+		version, err := parseVersion(value)
+		if err != nil {
+			return false
+		}
+		for _, constraints := range ranges {
+			if satisfiesAll(version, constraints) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	op := "="
+	for _, prefix := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			s = s[len(prefix):]
+			break
+		}
+	}
+	if op == "==" {
+		op = "="
+	}
+
+	version, err := parseVersion(s)
+	if err != nil {
+		return versionConstraint{}, err
+	}
+	return versionConstraint{op: op, version: version}, nil
+}
+
+// parseVersion parses a dotted version such as "v3.2.0-beta", ignoring pre-release and build suffixes
+func parseVersion(s string) ([]int, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+
+	parts := strings.Split(s, ".")
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func satisfiesAll(version []int, constraints []versionConstraint) bool {
+	for _, c := range constraints {
+		cmp := compareVersions(version, c.version)
+		var ok bool
+		switch c.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
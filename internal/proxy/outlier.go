@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	defaultOutlierConsecutiveErrors = 5
+	defaultOutlierBaseEjection      = 30 * time.Second
+	defaultOutlierMaxEjection       = 5 * time.Minute
+)
+
+// OutlierDetector ejects targets after consecutive errors seen by the reverse
+// proxy. Like the HealthChecker it outlives proxies, so rebuilding a proxy
+// does not bring an ejected target back early
+type OutlierDetector struct {
+	mu      sync.RWMutex
+	targets map[string]*outlierState // key is target ID
+}
+
+type outlierState struct {
+	proxyID      string
+	ejectedUntil atomic.Int64 // Unix nanoseconds, read on every selection
+
+	mu          sync.Mutex
+	consecutive int
+	ejections   int
+	halfOpen    bool
+}
+
+// outlierSettings are the outlier detection settings of a proxy with defaults applied
+type outlierSettings struct {
+	consecutiveErrors int
+	baseEjection      time.Duration
+	maxEjection       time.Duration
+	fallbackTargetID  string
+}
+
+func NewOutlierDetector() *OutlierDetector {
+	return &OutlierDetector{targets: make(map[string]*outlierState)}
+}
+
+// Ejected reports whether a target is currently ejected, d may be nil
+func (d *OutlierDetector) Ejected(targetID string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	state := d.targets[targetID]
+	d.mu.RUnlock()
+	return state != nil && time.Now().UnixNano() < state.ejectedUntil.Load()
+}
+
+// record counts the outcome of a request to a target and ejects it when needed
+func (d *OutlierDetector) record(proxyID, targetID string, success bool, settings *outlierSettings) {
+	if d == nil || settings == nil {
+		return
+	}
+
+	d.mu.RLock()
+	state := d.targets[targetID]
+	d.mu.RUnlock()
+	if state == nil {
+		if success {
+			return
+		}
+		d.mu.Lock()
+		if state = d.targets[targetID]; state == nil {
+			state = &outlierState{proxyID: proxyID}
+			d.targets[targetID] = state
+		}
+		d.mu.Unlock()
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if success {
+		state.consecutive = 0
+		if state.halfOpen {
+			state.halfOpen = false
+			state.ejections = 0
+			log.Printf("Target %s of proxy %s is restored after ejection", targetID, proxyID)
+		}
+		return
+	}
+
+	state.consecutive++
+	now := time.Now()
+	// Responses of requests that were sent before the ejection
+	if now.UnixNano() < state.ejectedUntil.Load() {
+		return
+	}
+	if !state.halfOpen && state.consecutive < settings.consecutiveErrors {
+		return
+	}
+
+	// Every ejection without a success in between lasts twice as long
+	duration := settings.baseEjection
+	for i := 0; i < state.ejections && duration < settings.maxEjection; i++ {
+		duration *= 2
+	}
+	if duration > settings.maxEjection {
+		duration = settings.maxEjection
+	}
+	state.ejections++
+	state.consecutive = 0
+	state.halfOpen = true
+	state.ejectedUntil.Store(now.Add(duration).UnixNano())
+	targetEjections.WithLabelValues(proxyID, targetID).Inc()
+	log.Printf("Target %s of proxy %s is ejected for %s", targetID, proxyID, duration)
+}
+
+// Status returns the outlier detection state of the targets of a proxy that had errors
+func (d *OutlierDetector) Status(proxyID string) []models.TargetEjection {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	now := time.Now()
+	statuses := []models.TargetEjection{}
+	for targetID, state := range d.targets {
+		if state.proxyID != proxyID {
+			continue
+		}
+		state.mu.Lock()
+		status := models.TargetEjection{
+			TargetID:          targetID,
+			HalfOpen:          state.halfOpen,
+			ConsecutiveErrors: state.consecutive,
+			Ejections:         state.ejections,
+		}
+		state.mu.Unlock()
+		if until := time.Unix(0, state.ejectedUntil.Load()); now.Before(until) {
+			status.Ejected = true
+			status.HalfOpen = false
+			status.EjectedUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].TargetID < statuses[j].TargetID })
+	return statuses
+}
+
+// Remove forgets the targets of a deleted proxy
+func (d *OutlierDetector) Remove(proxyID string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for targetID, state := range d.targets {
+		if state.proxyID == proxyID {
+			delete(d.targets, targetID)
+			targetEjections.DeleteLabelValues(proxyID, targetID)
+		}
+	}
+}
+
+func ValidateOutlierDetection(o *models.OutlierDetection) error {
+	_, err := compileOutlierDetection(o)
+	return err
+}
+
+func compileOutlierDetection(o *models.OutlierDetection) (*outlierSettings, error) {
+	if o == nil || !o.Enabled {
+		return nil, nil
+	}
+	if o.ConsecutiveErrors < 0 || o.BaseEjectionSeconds < 0 || o.MaxEjectionSeconds < 0 {
+		return nil, errors.New("outlier detection settings must not be negative")
+	}
+
+	settings := &outlierSettings{
+		consecutiveErrors: o.ConsecutiveErrors,
+		baseEjection:      time.Duration(o.BaseEjectionSeconds) * time.Second,
+		maxEjection:       time.Duration(o.MaxEjectionSeconds) * time.Second,
+		fallbackTargetID:  o.FallbackTargetID,
+	}
+	if settings.consecutiveErrors == 0 {
+		settings.consecutiveErrors = defaultOutlierConsecutiveErrors
+	}
+	if settings.baseEjection == 0 {
+		settings.baseEjection = defaultOutlierBaseEjection
+	}
+	if settings.maxEjection == 0 {
+		settings.maxEjection = defaultOutlierMaxEjection
+	}
+	if settings.maxEjection < settings.baseEjection {
+		return nil, errors.New("outlier detection max_ejection_seconds must not be less than base_ejection_seconds")
+	}
+	return settings, nil
+}
+
+// SetOutlierDetector sets the detector whose ejected targets are left out of the selection
+func (p *Proxy) SetOutlierDetector(outliers *OutlierDetector) {
+	p.outliers = outliers
+}
+
+// recordOutcome reports the result of a reverse proxied request for outlier detection
+func (p *Proxy) recordOutcome(targetID string, success bool) {
+	p.outliers.record(p.ID, targetID, success, p.outlier)
+}
+
+// stickyFallback returns the target for users whose sticky target is not available,
+// nil if they should be assigned anew
+func (p *Proxy) stickyFallback() *Target {
+	if p.outlier == nil || p.outlier.fallbackTargetID == "" {
+		return nil
+	}
+	return p.getTargetById(p.outlier.fallbackTargetID)
+}
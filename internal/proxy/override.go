@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	// OverrideParam is the query parameter carrying a signed override token
+	OverrideParam = "ab_force"
+	// OverrideHeader is the header carrying a signed override token
+	OverrideHeader = "X-AB-Force"
+)
+
+// overrides are the forced assignments of a proxy
+type overrides struct {
+	secret     []byte
+	byIdentity map[string]string // identity -> target ID
+}
+
+// SetOverrides sets the secret for override tokens and the allowlist of users
+// that always get a given target
+func (p *Proxy) SetOverrides(secret string, entries []models.Override) {
+	o := &overrides{
+		secret:     []byte(secret),
+		byIdentity: make(map[string]string, len(entries)),
+	}
+	for _, entry := range entries {
+		o.byIdentity[entry.Identity] = entry.TargetID
+	}
+	p.overrides = o
+}
+
+// SignOverride creates a token that forces the target until it expires,
+// in the form "<target_id>.<expires_unix>.<signature>"
+func SignOverride(secret, proxyID, targetID string, expires time.Time) string {
+	payload := targetID + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + overrideSignature(secret, proxyID, payload)
+}
+
+func overrideSignature(secret, proxyID, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(proxyID + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyOverride returns the target ID of a valid, unexpired token
+func verifyOverride(secret []byte, proxyID, token string, now time.Time) (string, bool) {
+	if len(secret) == 0 {
+		return "", false
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := token[:i], token[i+1:]
+	expected := overrideSignature(string(secret), proxyID, payload)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", false
+	}
+
+	targetID, expiresStr, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || now.Unix() > expires {
+		return "", false
+	}
+	return targetID, true
+}
+
+// overrideTarget returns the forced target of the request: a signed token wins over
+// the allowlist, which is checked for the RUID and then the proxy's identity source
+func (p *Proxy) overrideTarget(r *http.Request, info *RedirectInfo) *Target {
+	o := p.overrides
+	if o == nil {
+		return nil
+	}
+
+	token := r.URL.Query().Get(OverrideParam)
+	if token == "" {
+		token = r.Header.Get(OverrideHeader)
+	}
+	if token != "" {
+		if targetID, ok := verifyOverride(o.secret, p.ID, token, time.Now()); ok {
+			if target := p.getTargetById(targetID); target != nil {
+				return target
+			}
+		}
+	}
+
+	if len(o.byIdentity) == 0 || info == nil {
+		return nil
+	}
+	if targetID, ok := o.byIdentity[info.RUID]; ok {
+		return p.getTargetById(targetID)
+	}
+	if targetID, ok := o.byIdentity[p.identity(r, info)]; ok {
+		return p.getTargetById(targetID)
+	}
+	return nil
+}
+
+// stripOverride removes the override token so it is not passed on to targets
+func stripOverride(r *http.Request, info *RedirectInfo) {
+	r.Header.Del(OverrideHeader)
+	if query := r.URL.Query(); query.Has(OverrideParam) {
+		query.Del(OverrideParam)
+		r.URL.RawQuery = query.Encode()
+	}
+	if info != nil {
+		info.Query.Del(OverrideParam)
+	}
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// pathRewrite is a compiled path rewrite, a nil rewrite keeps the path
+type pathRewrite struct {
+	stripPrefix string
+	regex       *regexp.Regexp
+	replacement string
+	addPrefix   string
+}
+
+// ValidatePathRewrite checks the prefixes and the regex of a path rewrite
+func ValidatePathRewrite(rw *models.PathRewrite) error {
+	_, err := compilePathRewrite(rw)
+	return err
+}
+
+func compilePathRewrite(rw *models.PathRewrite) (*pathRewrite, error) {
+	if rw == nil || (rw.StripPrefix == "" && rw.Regex == "" && rw.AddPrefix == "") {
+		return nil, nil
+	}
+	for _, prefix := range []string{rw.StripPrefix, rw.AddPrefix} {
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("path prefix must start with /: %s", prefix)
+		}
+	}
+	if rw.Replacement != "" && rw.Regex == "" {
+		return nil, errors.New("path replacement requires a regex")
+	}
+
+	compiled := &pathRewrite{
+		stripPrefix: strings.TrimSuffix(rw.StripPrefix, "/"),
+		replacement: rw.Replacement,
+		addPrefix:   strings.TrimSuffix(rw.AddPrefix, "/"),
+	}
+	if rw.Regex != "" {
+		regex, err := regexp.Compile(rw.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path regex: %w", err)
+		}
+		compiled.regex = regex
+	}
+	return compiled, nil
+}
+
+// apply rewrites a request path, prefixes only match whole path segments
+func (rw *pathRewrite) apply(path string) string {
+	if rw == nil {
+		return path
+	}
+
+	if rw.stripPrefix != "" && (path == rw.stripPrefix || strings.HasPrefix(path, rw.stripPrefix+"/")) {
+		path = path[len(rw.stripPrefix):]
+	}
+	if rw.regex != nil {
+		path = rw.regex.ReplaceAllString(path, rw.replacement)
+	}
+	// A fully stripped path maps onto the bare prefix, a trailing slash is kept as sent
+	if rw.addPrefix != "" && path == "" {
+		path = rw.addPrefix
+	} else if rw.addPrefix != "" {
+		path = joinURLPath(rw.addPrefix, path)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// joinURLPath joins a base path and a request path with a single slash like
+// httputil.ReverseProxy does, so rewrites, redirects and mirrored requests build
+// the same paths as reverse proxied ones
+func joinURLPath(base, path string) string {
+	switch {
+	case base == "":
+		return path
+	case strings.HasSuffix(base, "/") && strings.HasPrefix(path, "/"):
+		return base + path[1:]
+	case !strings.HasSuffix(base, "/") && !strings.HasPrefix(path, "/"):
+		return base + "/" + path
+	}
+	return base + path
+}
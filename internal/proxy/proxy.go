@@ -2,44 +2,89 @@ package proxy
 
 import (
 	"fmt"
+	"net/netip"
 	"sync"
+	"sync/atomic"
 
+	"github.com/ab-testing-service/internal/geo"
 	"github.com/ab-testing-service/internal/models"
 )
 
 type Target struct {
-	ID       string  `json:"id"`
-	URL      string  `json:"url"`
-	Weight   float64 `json:"weight"`
-	IsActive bool    `json:"is_active"`
+	ID        string  `json:"id"`
+	URL       string  `json:"url"`
+	Weight    float64 `json:"weight"`
+	IsActive  bool    `json:"is_active"`
+	IsControl bool    `json:"is_control"`
+	// Active health check run by the supervisor
+	HealthCheck *models.HealthCheck `json:"health_check,omitempty"`
+	// Header rewrite rules applied after the rules of the proxy
+	Headers *models.HeaderRules `json:"headers,omitempty"`
+	// Maps the request path onto the path of the target
+	PathRewrite *models.PathRewrite `json:"path_rewrite,omitempty"`
 }
 
 type Config struct {
-	ID        string           `json:"id"`
-	ListenURL string           `json:"listen_url"`
-	Mode      models.ProxyMode `json:"mode"`
-	Targets   []Target         `json:"targets"`
-	Condition *Condition       `json:"condition"`
-	Tags      []string         `json:"tags"`
+	ID             string                 `json:"id"`
+	ListenURL      string                 `json:"listen_url"`
+	Mode           models.ProxyMode       `json:"mode"`
+	Targets        []Target               `json:"targets"`
+	Condition      *Condition             `json:"condition"`
+	Assignment     *models.Assignment     `json:"assignment,omitempty"`
+	TrustedProxies []string               `json:"trusted_proxies,omitempty"`
+	Schedule       *models.Schedule       `json:"schedule,omitempty"`
+	Bandit         *models.BanditSettings `json:"bandit,omitempty"`
+	// Name of a registered Selector, when empty it follows from the condition and assignment
+	Strategy string `json:"strategy,omitempty"`
+	// Bucket range of a layer of mutually exclusive experiments
+	Layer            *models.LayerAllocation   `json:"layer,omitempty"`
+	Mirror           *models.MirrorSettings    `json:"mirror,omitempty"`
+	Guardrails       *models.GuardrailSettings `json:"guardrails,omitempty"`
+	OutlierDetection *models.OutlierDetection  `json:"outlier_detection,omitempty"`
+	Retry            *models.RetryPolicy       `json:"retry,omitempty"`
+	Transport        *models.TransportSettings `json:"transport,omitempty"`
+	Headers          *models.HeaderRules       `json:"headers,omitempty"`
+	Cookie           *models.CookiePolicy      `json:"cookie,omitempty"`
+	Consent          *models.ConsentPolicy     `json:"consent,omitempty"`
+	Tags             []string                  `json:"tags"`
 }
 
 type Condition struct {
 	Type      models.ConditionType `json:"type"`
 	ParamName string               `json:"param_name"`
+	Operator  models.MatchOperator `json:"operator,omitempty"`
 	Values    map[string]string    `json:"values"`
 	Default   string               `json:"default"`
+	Rules     []models.RouteRule   `json:"rules,omitempty"`
 }
 
 type Proxy struct {
-	ID         string
-	ListenURL  string
-	Mode       models.ProxyMode
-	Targets    []Target
-	Config     Config
-	mutex      sync.RWMutex
-	metrics    *Metrics
-	cookieName string
-	stats      *Stats
+	ID        string
+	ListenURL string
+	Mode      models.ProxyMode
+	Config    Config
+	metrics   *Metrics
+	cookie    cookiePolicy
+	stats     *Stats
+	selector  Selector
+
+	// Requests read the routing state without locking, mutex serializes its writers
+	routing atomic.Pointer[routing]
+	mutex   sync.Mutex
+
+	trustedProxies []netip.Prefix
+	geo            *geo.Resolver
+	holdout        *Holdout
+	overrides      *overrides
+	mirror         *mirror
+	schedule       *compiledSchedule
+	health         *HealthChecker
+	outliers       *OutlierDetector
+	outlier        *outlierSettings
+	retry          *retryPolicy
+	transport      transportSettings
+	headers        *headerRules
+	consent        *consentPolicy
 }
 
 func NewProxy(cfg Config) (*Proxy, error) {
@@ -55,15 +100,79 @@ func NewProxy(cfg Config) (*Proxy, error) {
 		}
 	}
 
+	trustedProxies, err := parsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	schedule, err := compileSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+
+	mirror, err := compileMirror(cfg.Mirror)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror: %w", err)
+	}
+
+	outlier, err := compileOutlierDetection(cfg.OutlierDetection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outlier detection: %w", err)
+	}
+
+	retry, err := compileRetry(cfg.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %w", err)
+	}
+
+	transport, err := compileTransport(cfg.Transport)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport settings: %w", err)
+	}
+
+	headers, err := compileHeaderRules(cfg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header rules: %w", err)
+	}
+
+	cookie, err := compileCookiePolicy(cfg.ID, cfg.Cookie)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookie policy: %w", err)
+	}
+
+	consent, err := compileConsent(cfg.Consent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consent policy: %w", err)
+	}
+
 	proxy := &Proxy{
-		ID:         cfg.ID,
-		ListenURL:  cfg.ListenURL,
-		Mode:       cfg.Mode,
-		Targets:    cfg.Targets,
-		Config:     cfg,
-		metrics:    newProxyMetrics(cfg.ID),
-		cookieName: fmt.Sprintf("proxy_%s", cfg.ID),
-		stats:      NewProxyStats(),
+		ID:        cfg.ID,
+		ListenURL: cfg.ListenURL,
+		Mode:      cfg.Mode,
+		Config:    cfg,
+		metrics:   newProxyMetrics(cfg.ID),
+		cookie:    cookie,
+		stats:     NewProxyStats(),
+
+		trustedProxies: trustedProxies,
+		schedule:       schedule,
+		mirror:         mirror,
+		outlier:        outlier,
+		retry:          retry,
+		transport:      transport,
+		headers:        headers,
+		consent:        consent,
+	}
+
+	routing, err := proxy.newRouting(cfg.Targets, nil)
+	if err != nil {
+		return nil, err
+	}
+	proxy.routing.Store(routing)
+
+	proxy.selector, err = newSelector(proxy)
+	if err != nil {
+		return nil, err
 	}
 
 	return proxy, nil
@@ -79,6 +188,9 @@ func validate(cfg Config) (float64, error) {
 	if len(cfg.Targets) == 0 {
 		return 0, fmt.Errorf("at least one target is required")
 	}
+	if cfg.Assignment != nil && cfg.Assignment.Mode != "" && !cfg.Assignment.Mode.IsValid() {
+		return 0, fmt.Errorf("invalid assignment mode: %s", cfg.Assignment.Mode)
+	}
 
 	// Validate and normalize target weights
 	var totalWeight float64
@@ -91,12 +203,39 @@ func validate(cfg Config) (float64, error) {
 	return totalWeight, nil
 }
 
+// SetGeoResolver sets the resolver used by geo conditions, without it every lookup is unknown
+func (p *Proxy) SetGeoResolver(resolver *geo.Resolver) {
+	p.geo = resolver
+}
+
 func (p *Proxy) UpdateTargets(targets []Target) {
 	p.mutex.Lock()
-	p.Targets = targets
-	p.mutex.Unlock()
+	defer p.mutex.Unlock()
+
+	previous := p.snapshot()
+	// Updates keep the rules of the previous state and cannot fail
+	current, _ := p.newRouting(targets, previous)
+	p.routing.Store(current)
+	closeUnused(previous.upstreams, current.upstreams)
 }
 
 func (p *Proxy) GetStats() *Stats {
 	return p.stats
 }
+
+// ReuseState takes over the statistics of the proxy this one replaces, so the
+// counters since the last flush are not lost and requests still served by the
+// previous proxy count towards them, as well as the latency averages of the
+// least latency strategy. It must be called before the proxy serves requests
+func (p *Proxy) ReuseState(previous *Proxy) {
+	if previous == nil || previous == p {
+		return
+	}
+	p.stats = previous.stats
+
+	if selector, ok := previous.selector.(*leastLatencySelector); ok {
+		if _, same := p.selector.(*leastLatencySelector); same {
+			p.selector = selector
+		}
+	}
+}
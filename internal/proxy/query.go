@@ -14,12 +14,22 @@ type RedirectInfo struct {
 	RRID  string // Redirect Request ID (unique per click)
 	RUID  string // Redirect User ID (unique per user)
 	Query url.Values
+
+	// Anonymous users have not given consent, their RUID is only valid for the
+	// request and no identifiers are stored on their side
+	Anonymous bool
 }
 
-func (p *Proxy) appendRedirectParams(targetURL string, info *RedirectInfo) string {
-	u, err := url.Parse(targetURL)
+func (p *Proxy) appendRedirectParams(target *Target, path string, info *RedirectInfo) string {
+	u, err := url.Parse(target.URL)
 	if err != nil {
-		return targetURL
+		return target.URL
+	}
+
+	// Targets with a path rewrite receive the rewritten request path under their own path
+	if rewrite := p.snapshot().paths[target.ID]; rewrite != nil {
+		u.Path = joinURLPath(u.Path, rewrite.apply(path))
+		u.RawPath = ""
 	}
 
 	// Get existing query parameters
@@ -28,7 +38,9 @@ func (p *Proxy) appendRedirectParams(targetURL string, info *RedirectInfo) strin
 	// Add redirect info parameters
 	query.Set("rid", info.RID)
 	query.Set("rrid", info.RRID)
-	query.Set("ruid", info.RUID)
+	if !info.Anonymous {
+		query.Set("ruid", info.RUID)
+	}
 
 	// Add all original query parameters
 	for key, values := range info.Query {
@@ -43,12 +55,23 @@ func (p *Proxy) appendRedirectParams(targetURL string, info *RedirectInfo) strin
 	return u.String()
 }
 
-func (p *Proxy) getOrCreateRedirectInfo(r *http.Request) (*RedirectInfo, error) {
-	// Get or generate RUID from cookie
+// getOrCreateRedirectInfo builds the identifiers of the request. The RUID is
+// only taken from the query string on the internal redirect hop, elsewhere any
+// client could pick its own bucket or take over the RUID of a shared link
+func (p *Proxy) getOrCreateRedirectInfo(r *http.Request, anonymous, internalRedirect bool) (*RedirectInfo, error) {
+	// Get RUID from cookie, then from the query string of an internal redirect, or generate a new one
 	ruidCookie, err := r.Cookie("ruid")
 	var ruid string
-	if errors.Is(err, http.ErrNoCookie) || ruidCookie == nil {
+	if anonymous {
+		// Identifiers left from before consent was withdrawn are not used
 		ruid = uuid.New().String()
+	} else if errors.Is(err, http.ErrNoCookie) || ruidCookie == nil {
+		if internalRedirect {
+			ruid = r.URL.Query().Get("ruid")
+		}
+		if ruid == "" {
+			ruid = uuid.New().String()
+		}
 	} else {
 		ruid = ruidCookie.Value
 	}
@@ -63,9 +86,10 @@ func (p *Proxy) getOrCreateRedirectInfo(r *http.Request) (*RedirectInfo, error)
 	query := r.URL.Query()
 
 	return &RedirectInfo{
-		RID:   rid,
-		RRID:  rrid,
-		RUID:  ruid,
-		Query: query,
+		RID:       rid,
+		RRID:      rrid,
+		RUID:      ruid,
+		Query:     query,
+		Anonymous: anonymous,
 	}, nil
 }
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	defaultRetryAttempts     = 1
+	defaultRetryMaxBody      = 64 << 10
+	defaultRetryBudgetRatio  = 0.2
+	defaultRetryBudgetMin    = 3
+	retryBudgetWindow        = 10 * time.Second
+	maxRetryAttempts         = 5
+	retryOutcomeRetried      = "retried"
+	retryOutcomeBudget       = "budget_exhausted"
+	retryOutcomeNoTarget     = "no_target"
+	retryOutcomeBodyTooLarge = "body_too_large"
+)
+
+// defaultRetryMethods are idempotent, other methods are only retried when configured
+var defaultRetryMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+type retryPolicy struct {
+	attempts   int
+	methods    map[string]bool
+	maxBody    int64
+	sameTarget bool
+	budget     *retryBudget
+}
+
+// retryBudget caps the retries of a proxy to a share of its retryable requests,
+// counted in fixed windows
+type retryBudget struct {
+	ratio float64
+	min   int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+// retryState follows the retries of a single request, nil when it is not retried
+type retryState struct {
+	body      []byte
+	remaining int
+	tried     []string // IDs of the targets that failed
+}
+
+// ValidateRetry checks the retry attempts, methods and budget
+func ValidateRetry(r *models.RetryPolicy) error {
+	_, err := compileRetry(r)
+	return err
+}
+
+func compileRetry(r *models.RetryPolicy) (*retryPolicy, error) {
+	if r == nil || !r.Enabled {
+		return nil, nil
+	}
+	if r.Attempts < 0 || r.MaxBodyBytes < 0 || r.BudgetRatio < 0 || r.BudgetMinRetries < 0 {
+		return nil, errors.New("retry settings must not be negative")
+	}
+	if r.Attempts > maxRetryAttempts {
+		return nil, fmt.Errorf("retry attempts must not exceed %d", maxRetryAttempts)
+	}
+	if r.BudgetRatio > 1 {
+		return nil, errors.New("retry budget_ratio must not exceed 1")
+	}
+
+	policy := &retryPolicy{
+		attempts:   r.Attempts,
+		methods:    make(map[string]bool),
+		maxBody:    r.MaxBodyBytes,
+		sameTarget: r.SameTarget,
+		budget: &retryBudget{
+			ratio: r.BudgetRatio,
+			min:   r.BudgetMinRetries,
+		},
+	}
+	if policy.attempts == 0 {
+		policy.attempts = defaultRetryAttempts
+	}
+	if policy.maxBody == 0 {
+		policy.maxBody = defaultRetryMaxBody
+	}
+	if policy.budget.ratio == 0 {
+		policy.budget.ratio = defaultRetryBudgetRatio
+	}
+	if policy.budget.min == 0 {
+		policy.budget.min = defaultRetryBudgetMin
+	}
+
+	methods := r.Methods
+	if len(methods) == 0 {
+		methods = defaultRetryMethods
+	}
+	for _, method := range methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" {
+			return nil, errors.New("retry methods must not be empty")
+		}
+		policy.methods[method] = true
+	}
+	return policy, nil
+}
+
+// request counts a retryable request
+func (b *retryBudget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	b.requests++
+}
+
+// allow takes a retry from the budget if one is left
+func (b *retryBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	if float64(b.retries) >= float64(b.min)+b.ratio*float64(b.requests) {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+func (b *retryBudget) roll() {
+	if now := time.Now(); now.Sub(b.windowStart) >= retryBudgetWindow {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
+
+// prepareRetry decides whether the request may be retried and buffers its body
+// so it can be sent again, bodies over the limit are left alone and not retried
+func (p *Proxy) prepareRetry(r *http.Request, target *Target) *retryState {
+	policy := p.retry
+	if policy == nil || !policy.methods[r.Method] {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > policy.maxBody {
+			p.metrics.Retries.WithLabelValues(target.URL, retryOutcomeBodyTooLarge).Inc()
+			return nil
+		}
+		buf, err := io.ReadAll(io.LimitReader(r.Body, policy.maxBody+1))
+		if err != nil || int64(len(buf)) > policy.maxBody {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+			if err == nil {
+				p.metrics.Retries.WithLabelValues(target.URL, retryOutcomeBodyTooLarge).Inc()
+			}
+			return nil
+		}
+		body = buf
+	}
+
+	policy.budget.request()
+	state := &retryState{body: body, remaining: policy.attempts}
+	state.rewind(r)
+	return state
+}
+
+// rewind restores the buffered body before the request is sent
+func (s *retryState) rewind(r *http.Request) {
+	if s == nil || s.body == nil {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(s.body))
+	r.ContentLength = int64(len(s.body))
+}
+
+// retryTarget returns the target to retry a request on after it failed on the
+// given target, nil if the request must not be retried
+func (p *Proxy) retryTarget(r *http.Request, failed *Target, state *retryState) *Target {
+	if state == nil || state.remaining == 0 || r.Context().Err() != nil {
+		return nil
+	}
+	state.tried = append(state.tried, failed.ID)
+
+	next := p.nextRetryTarget(failed, state.tried)
+	if next == nil {
+		p.metrics.Retries.WithLabelValues(failed.URL, retryOutcomeNoTarget).Inc()
+		return nil
+	}
+	if !p.retry.budget.allow() {
+		p.metrics.Retries.WithLabelValues(failed.URL, retryOutcomeBudget).Inc()
+		return nil
+	}
+
+	state.remaining--
+	p.metrics.Retries.WithLabelValues(failed.URL, retryOutcomeRetried).Inc()
+	return next
+}
+
+// nextRetryTarget returns the failed target itself or the next available target
+// after it that has not failed yet, by the order of the targets. Targets without
+// weight, such as rolled back ones, are only used when no other target is left
+func (p *Proxy) nextRetryTarget(failed *Target, tried []string) *Target {
+	if p.retry.sameTarget {
+		return p.getTargetById(failed.ID)
+	}
+
+	targets := p.snapshot().targets
+	start := 0
+	for i, target := range targets {
+		if target.ID == failed.ID {
+			start = i + 1
+			break
+		}
+	}
+	for _, weighted := range []bool{true, false} {
+		for i := 0; i < len(targets); i++ {
+			target := &targets[(start+i)%len(targets)]
+			if (weighted && target.Weight == 0) || !p.available(*target) || containsString(tried, target.ID) {
+				continue
+			}
+			return target
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
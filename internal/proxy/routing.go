@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// routing is the routing state of a proxy. It is never modified once built:
+// UpdateTargets builds a new one and swaps it in atomically, so requests read
+// it without taking any lock and pointers into it stay valid
+type routing struct {
+	targets   []Target
+	active    *targetTable // Active targets in order
+	routes    []compiledRoute
+	matchers  []targetMatcher
+	upstreams map[string]*upstream    // key is target ID
+	headers   map[string]*headerRules // key is target ID
+	paths     map[string]*pathRewrite // key is target ID
+}
+
+// targetTable is a list of targets with their cumulative weights
+type targetTable struct {
+	targets    []Target
+	cumulative []float64
+}
+
+// tableSelector is implemented by selectors that pick from the cumulative
+// weights directly, so the table is not rebuilt on every request
+type tableSelector interface {
+	selectFrom(r *http.Request, info *RedirectInfo, table *targetTable) (*Target, error)
+}
+
+func newTargetTable(targets []Target) *targetTable {
+	table := &targetTable{
+		targets:    targets,
+		cumulative: make([]float64, len(targets)),
+	}
+	var total float64
+	for i, target := range targets {
+		total += target.Weight
+		table.cumulative[i] = total
+	}
+	return table
+}
+
+// pick maps a point in [0, 1) onto the cumulative weights
+func (t *targetTable) pick(point float64) *Target {
+	if len(t.targets) == 0 {
+		return nil
+	}
+	total := t.cumulative[len(t.cumulative)-1]
+	i := sort.SearchFloat64s(t.cumulative, point*total)
+	if i == len(t.targets) {
+		// Fallback to the first active target if something goes wrong with the weighted selection
+		i = 0
+	}
+	return &t.targets[i]
+}
+
+// newRouting builds the routing state for the targets, reusing the upstreams of
+// the previous state. It only fails for a new proxy, updates keep the rules of
+// the previous state if the condition does not compile for the new targets
+func (p *Proxy) newRouting(targets []Target, previous *routing) (*routing, error) {
+	routes, matchers, err := compileCondition(p.Config.Condition, targets)
+	if err != nil {
+		if previous == nil {
+			return nil, err
+		}
+		log.Printf("Failed to compile condition of proxy %s: %v", p.ID, err)
+		routes, matchers = previous.routes, previous.matchers
+	}
+
+	rt := &routing{
+		// The caller keeps its slice, the state must not change under requests
+		targets:  append([]Target(nil), targets...),
+		routes:   routes,
+		matchers: matchers,
+	}
+
+	var active []Target
+	for _, target := range targets {
+		if target.IsActive {
+			active = append(active, target)
+		}
+	}
+	rt.active = newTargetTable(active)
+
+	var current map[string]*upstream
+	if previous != nil {
+		current = previous.upstreams
+	}
+	rt.upstreams = buildUpstreams(targets, current, p.transport)
+
+	rt.headers = make(map[string]*headerRules)
+	rt.paths = make(map[string]*pathRewrite)
+	for _, target := range targets {
+		rewrite, err := compilePathRewrite(target.PathRewrite)
+		if err != nil {
+			if previous == nil {
+				return nil, fmt.Errorf("invalid path rewrite of target %s: %w", target.ID, err)
+			}
+			log.Printf("Failed to compile path rewrite of target %s: %v", target.ID, err)
+		} else if rewrite != nil {
+			rt.paths[target.ID] = rewrite
+		}
+
+		headers, err := compileHeaderRules(target.Headers)
+		if err != nil {
+			if previous == nil {
+				return nil, fmt.Errorf("invalid header rules of target %s: %w", target.ID, err)
+			}
+			log.Printf("Failed to compile header rules of target %s: %v", target.ID, err)
+			continue
+		}
+		if headers != nil {
+			rt.headers[target.ID] = headers
+		}
+	}
+	return rt, nil
+}
+
+// snapshot returns the current routing state
+func (p *Proxy) snapshot() *routing {
+	return p.routing.Load()
+}
+
+// Targets returns the current targets of the proxy, the slice must not be modified
+func (p *Proxy) Targets() []Target {
+	return p.snapshot().targets
+}
+
+// availableTable returns the active targets that pass their health checks and are
+// not ejected, the precomputed table when all of them are. If none is available,
+// all active targets are returned rather than failing every request
+func (rt *routing) availableTable(p *Proxy) *targetTable {
+	all := true
+	for _, target := range rt.active.targets {
+		if !p.available(target) {
+			all = false
+			break
+		}
+	}
+	if all {
+		return rt.active
+	}
+
+	var healthy []Target
+	for _, target := range rt.active.targets {
+		if p.available(target) {
+			healthy = append(healthy, target)
+		}
+	}
+	if len(healthy) == 0 {
+		return rt.active
+	}
+	return newTargetTable(healthy)
+}
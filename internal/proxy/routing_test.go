@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+func benchmarkTargets() []Target {
+	return []Target{
+		{ID: "control", URL: "http://control.example.com", Weight: 0.4, IsActive: true, IsControl: true},
+		{ID: "a", URL: "http://a.example.com", Weight: 0.2, IsActive: true},
+		{ID: "b", URL: "http://b.example.com", Weight: 0.2, IsActive: true},
+		{ID: "c", URL: "http://c.example.com", Weight: 0.2, IsActive: true},
+	}
+}
+
+// whileUpdating runs fn while the targets of p are replaced in a loop
+func whileUpdating(p *Proxy, fn func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				p.UpdateTargets(benchmarkTargets())
+			}
+		}
+	}()
+	fn()
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkSelectTarget(b *testing.B) {
+	p, err := NewProxy(Config{
+		ID:        "bench",
+		ListenURL: "bench.local:80",
+		Mode:      models.ProxyModeRedirect,
+		Targets:   benchmarkTargets(),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	info := &RedirectInfo{RID: "rid", RRID: "rrid", RUID: "ruid"}
+
+	run := func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetParallelism(16)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			r := httptest.NewRequest(http.MethodGet, "http://bench.local/", nil)
+			for pb.Next() {
+				if _, _, _, err := p.selectTarget(r, info); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	b.Run("idle", run)
+	b.Run("updating", func(b *testing.B) {
+		whileUpdating(p, func() { run(b) })
+	})
+}
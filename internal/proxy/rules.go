@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// compiledRule is a rule tree with its operators and patterns precompiled
+type compiledRule struct {
+	all  []*compiledRule
+	any  []*compiledRule
+	not  *compiledRule
+	leaf *compiledLeaf
+}
+
+type compiledLeaf struct {
+	conditionType models.ConditionType
+	paramName     string
+	match         valueMatcher
+}
+
+// compiledRoute sends requests matching the rule to the target
+type compiledRoute struct {
+	targetID string
+	rule     *compiledRule
+}
+
+// targetMatcher matches the single condition value configured for a target
+type targetMatcher struct {
+	targetID string
+	match    valueMatcher
+}
+
+// ValidateRule checks the rule tree structure and compiles its operators,
+// so invalid patterns are rejected before they reach a proxy
+func ValidateRule(node *models.RuleNode) error {
+	_, err := compileRule(node)
+	return err
+}
+
+func compileRule(node *models.RuleNode) (*compiledRule, error) {
+	if err := node.Validate(); err != nil {
+		return nil, err
+	}
+	return compileNode(node)
+}
+
+func compileNode(node *models.RuleNode) (*compiledRule, error) {
+	switch {
+	case len(node.All) > 0:
+		children, err := compileNodes(node.All)
+		return &compiledRule{all: children}, err
+	case len(node.Any) > 0:
+		children, err := compileNodes(node.Any)
+		return &compiledRule{any: children}, err
+	case node.Not != nil:
+		child, err := compileNode(node.Not)
+		return &compiledRule{not: child}, err
+	}
+
+	op := defaultOperator(node.Type, node.Operator)
+	match, err := compileMatcher(op, normalizeValues(node.Type, op, node.Values))
+	if err != nil {
+		return nil, err
+	}
+	return &compiledRule{leaf: &compiledLeaf{
+		conditionType: node.Type,
+		paramName:     node.ParamName,
+		match:         match,
+	}}, nil
+}
+
+func compileNodes(nodes []models.RuleNode) ([]*compiledRule, error) {
+	compiled := make([]*compiledRule, len(nodes))
+	for i := range nodes {
+		rule, err := compileNode(&nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+// compileCondition precompiles the rule trees and the per-target values of the condition
+func compileCondition(condition *Condition, targets []Target) ([]compiledRoute, []targetMatcher, error) {
+	if condition == nil {
+		return nil, nil, nil
+	}
+
+	routes := make([]compiledRoute, len(condition.Rules))
+	for i := range condition.Rules {
+		rule, err := compileRule(&condition.Rules[i].Match)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid rule %d: %w", i, err)
+		}
+		routes[i] = compiledRoute{targetID: condition.Rules[i].TargetID, rule: rule}
+	}
+
+	// Values are keyed by target ID, keep the order of targets for deterministic matching
+	var matchers []targetMatcher
+	for _, target := range targets {
+		value, ok := condition.Values[target.ID]
+		if !ok {
+			continue
+		}
+		op := defaultOperator(condition.Type, condition.Operator)
+		match, err := compileMatcher(op, normalizeValues(condition.Type, op, []string{value}))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid condition value for target %s: %w", target.ID, err)
+		}
+		matchers = append(matchers, targetMatcher{targetID: target.ID, match: match})
+	}
+
+	return routes, matchers, nil
+}
+
+// matches evaluates the compiled rule tree against the request
+func (c *compiledRule) matches(p *Proxy, r *http.Request) bool {
+	switch {
+	case c.all != nil:
+		for _, child := range c.all {
+			if !child.matches(p, r) {
+				return false
+			}
+		}
+		return true
+	case c.any != nil:
+		for _, child := range c.any {
+			if child.matches(p, r) {
+				return true
+			}
+		}
+		return false
+	case c.not != nil:
+		return !c.not.matches(p, r)
+	}
+
+	return c.leaf.match(p.requestValue(r, c.leaf.conditionType, c.leaf.paramName))
+}
+
+// defaultOperator returns the operator used when none is configured for the condition type
+func defaultOperator(conditionType models.ConditionType, op models.MatchOperator) models.MatchOperator {
+	if op == "" && conditionType == models.ConditionTypeIP {
+		return models.MatchOperatorCIDR
+	}
+	return op
+}
+
+// foldCase returns how values of a case-insensitive condition type are folded, nil
+// for case-sensitive types. requestValue folds the request value the same way:
+// methods and geo codes are upper-cased, hosts lower-cased
+func foldCase(conditionType models.ConditionType) func(string) string {
+	switch conditionType {
+	case models.ConditionTypeMethod, models.ConditionTypeGeo:
+		return strings.ToUpper
+	case models.ConditionTypeHost:
+		return strings.ToLower
+	}
+	return nil
+}
+
+// normalizeValues folds the values of case-insensitive condition types, regexes
+// are made case-insensitive instead so their escapes keep their meaning
+func normalizeValues(conditionType models.ConditionType, op models.MatchOperator, values []string) []string {
+	fold := foldCase(conditionType)
+	if fold == nil {
+		return values
+	}
+	normalized := make([]string, len(values))
+	for i, v := range values {
+		if op == models.MatchOperatorRegex {
+			normalized[i] = "(?i)" + v
+		} else {
+			normalized[i] = fold(v)
+		}
+	}
+	return normalized
+}
+
+// ValidateMatch checks that every value compiles for the operator
+func ValidateMatch(conditionType models.ConditionType, op models.MatchOperator, values []string) error {
+	for _, v := range values {
+		if _, err := compileMatcher(defaultOperator(conditionType, op), []string{v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+const minutesPerDay = 24 * 60
+
+// compiledSchedule is a schedule with its timezone and windows parsed
+type compiledSchedule struct {
+	start    *time.Time
+	end      *time.Time
+	location *time.Location
+	windows  []compiledWindow
+}
+
+type compiledWindow struct {
+	days [7]bool
+	from int // minutes since midnight, inclusive
+	to   int // minutes since midnight, exclusive
+}
+
+// ValidateSchedule checks the timezone, weekdays and times of the schedule
+func ValidateSchedule(schedule *models.Schedule) error {
+	_, err := compileSchedule(schedule)
+	return err
+}
+
+func compileSchedule(schedule *models.Schedule) (*compiledSchedule, error) {
+	if schedule == nil {
+		return nil, nil
+	}
+
+	location := time.UTC
+	if schedule.Timezone != "" {
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", schedule.Timezone, err)
+		}
+		location = loc
+	}
+
+	if schedule.Start != nil && schedule.End != nil && !schedule.End.After(*schedule.Start) {
+		return nil, fmt.Errorf("schedule end must be after start")
+	}
+
+	compiled := &compiledSchedule{
+		start:    schedule.Start,
+		end:      schedule.End,
+		location: location,
+	}
+
+	for i, w := range schedule.Windows {
+		window := compiledWindow{to: minutesPerDay}
+		if len(w.Weekdays) == 0 {
+			for d := range window.days {
+				window.days[d] = true
+			}
+		}
+		for _, name := range w.Weekdays {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("window %d: invalid weekday %q", i, name)
+			}
+			window.days[day] = true
+		}
+
+		var err error
+		if w.StartTime != "" {
+			if window.from, err = parseClock(w.StartTime); err != nil {
+				return nil, fmt.Errorf("window %d: %w", i, err)
+			}
+		}
+		if w.EndTime != "" {
+			if window.to, err = parseClock(w.EndTime); err != nil {
+				return nil, fmt.Errorf("window %d: %w", i, err)
+			}
+		}
+		if window.from == window.to {
+			return nil, fmt.Errorf("window %d: start and end time must differ", i)
+		}
+		compiled.windows = append(compiled.windows, window)
+	}
+
+	return compiled, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// active reports whether the experiment runs at the given moment
+func (s *compiledSchedule) active(now time.Time) bool {
+	if s == nil {
+		return true
+	}
+	if s.start != nil && now.Before(*s.start) {
+		return false
+	}
+	if s.end != nil && !now.Before(*s.end) {
+		return false
+	}
+	if len(s.windows) == 0 {
+		return true
+	}
+
+	local := now.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, w := range s.windows {
+		if w.contains(local.Weekday(), minute) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w compiledWindow) contains(day time.Weekday, minute int) bool {
+	if w.from < w.to {
+		return w.days[day] && minute >= w.from && minute < w.to
+	}
+
+	// Window spans midnight, the part after midnight belongs to the previous day
+	if w.days[day] && minute >= w.from {
+		return true
+	}
+	previous := (day + 6) % 7
+	return w.days[previous] && minute < w.to
+}
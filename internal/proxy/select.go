@@ -2,53 +2,83 @@ package proxy
 
 import (
 	"fmt"
-	"math/rand"
 	"net/http"
+	"time"
 )
 
-func (p *Proxy) selectTarget(r *http.Request) (*Target, error) {
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
+// selectTarget returns the target of the request and the cohort of the user.
+// sticky reports whether the user should be kept on the target with a cookie
+func (p *Proxy) selectTarget(r *http.Request, info *RedirectInfo) (target *Target, cohort Cohort, sticky bool, err error) {
+	// Forced targets for QA and staff win over everything else
+	if target := p.overrideTarget(r, info); target != nil {
+		return target, CohortOverride, false, nil
+	}
 
-	// First, try to get target from cookie
-	if target := p.getTargetFromCookie(r); target != nil {
-		return target, nil
+	// Users without consent cannot be followed across requests. They go through the
+	// same selection with an identity of their own request, are never kept on a
+	// target and are counted apart from the experiment
+	anonymous := info != nil && info.Anonymous
+	exposed := CohortExposed
+	if anonymous {
+		exposed = CohortAnonymous
 	}
 
-	// Then, check routing conditions if present
-	if p.Config.Condition != nil {
-		if target := p.getTargetByCondition(r); target != nil {
-			return target, nil
+	// The global holdout never sees any variant, membership follows the RUID so it
+	// is the same on every proxy whatever identity the proxy buckets by
+	if info != nil && p.holdout.Contains(info.RUID) {
+		if target := p.controlTarget(); target != nil {
+			return target, CohortHoldout, false, nil
 		}
-		return nil, fmt.Errorf("no matching target found")
+		return nil, "", false, fmt.Errorf("no active targets available")
 	}
 
-	// Fall back to weighted random selection if no condition matches or no condition is set
-	var totalWeight float64
-	var activeTargets []Target
+	// Outside of the schedule the experiment is off and everyone gets the default target
+	if !p.schedule.active(time.Now()) {
+		if target := p.defaultTarget(); target != nil {
+			return target, exposed, false, nil
+		}
+		return nil, "", false, fmt.Errorf("no active targets available")
+	}
 
-	for _, target := range p.Targets {
-		if target.IsActive {
-			totalWeight += target.Weight
-			activeTargets = append(activeTargets, target)
+	// Users outside of the proxy's buckets belong to another experiment of the layer
+	if !p.inLayer(r, info) {
+		if target := p.controlTarget(); target != nil {
+			return target, CohortLayerExcluded, false, nil
 		}
+		return nil, "", false, fmt.Errorf("no active targets available")
 	}
 
-	if len(activeTargets) == 0 {
-		return nil, fmt.Errorf("no active targets available")
+	// First, try to get target from cookie
+	sticky = p.sticky() && !anonymous
+	if sticky {
+		if target := p.getTargetFromCookie(r); target != nil {
+			return target, CohortExposed, false, nil
+		}
 	}
 
-	// Choose a random target based on weights
-	rnd := rand.Float64() * totalWeight
-	var cumulativeWeight float64
+	// Then let the proxy's strategy choose among the active targets
+	table := p.snapshot().availableTable(p)
 
-	for _, target := range activeTargets {
-		cumulativeWeight += target.Weight
-		if rnd <= cumulativeWeight {
-			return &target, nil
-		}
+	if selector, ok := p.selector.(tableSelector); ok {
+		target, err = selector.selectFrom(r, info, table)
+	} else {
+		target, err = p.selector.Select(r, info, table.targets)
+	}
+	if err != nil {
+		return nil, "", false, err
 	}
+	return target, exposed, sticky, nil
+}
 
-	// Fallback to the first active target if something goes wrong with the random selection
-	return &activeTargets[0], nil
+// defaultTarget returns the condition's default target, or the first active target if none is set
+func (p *Proxy) defaultTarget() *Target {
+	if p.Config.Condition != nil && p.Config.Condition.Default != "" {
+		if target := p.getTargetById(p.Config.Condition.Default); target != nil {
+			return target
+		}
+	}
+	if targets := p.snapshot().availableTable(p).targets; len(targets) > 0 {
+		return &targets[0]
+	}
+	return nil
 }
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Built-in target selection strategies
+const (
+	StrategyWeightedRandom = "weighted_random"
+	StrategyHash           = "hash"
+	StrategyRoundRobin     = "round_robin"
+	StrategyLeastLatency   = "least_latency"
+	StrategyBandit         = "bandit"
+	StrategyCondition      = "condition"
+)
+
+// Selector chooses the target for a request that has no sticky assignment yet.
+// Select receives the available active targets of the proxy's current routing
+// state, which is shared by concurrent requests and must not be modified.
+type Selector interface {
+	Select(r *http.Request, info *RedirectInfo, targets []Target) (*Target, error)
+}
+
+// LatencyObserver is implemented by selectors that want to know how long
+// requests to each target took in reverse proxy mode
+type LatencyObserver interface {
+	ObserveLatency(targetID string, d time.Duration)
+}
+
+// SelectorFactory creates the selector of a proxy, it is called once per proxy
+// after its configuration has been validated
+type SelectorFactory func(p *Proxy) (Selector, error)
+
+var (
+	selectorsMu sync.RWMutex
+	selectors   = map[string]SelectorFactory{
+		StrategyWeightedRandom: func(p *Proxy) (Selector, error) { return weightedSelector{point: randomPoint}, nil },
+		StrategyHash:           func(p *Proxy) (Selector, error) { return weightedSelector{point: p.hashPoint}, nil },
+		StrategyRoundRobin:     func(p *Proxy) (Selector, error) { return &roundRobinSelector{}, nil },
+		StrategyLeastLatency:   func(p *Proxy) (Selector, error) { return newLeastLatencySelector(), nil },
+		// Bandit weights are recomputed by the supervisor, requests follow the current weights
+		StrategyBandit:    func(p *Proxy) (Selector, error) { return weightedSelector{point: randomPoint}, nil },
+		StrategyCondition: newConditionSelector,
+	}
+)
+
+// RegisterSelector makes a strategy available to proxies under the given name.
+// It panics if the name is empty or already registered.
+func RegisterSelector(name string, factory SelectorFactory) {
+	selectorsMu.Lock()
+	defer selectorsMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("proxy: RegisterSelector requires a name and a factory")
+	}
+	if _, exists := selectors[name]; exists {
+		panic("proxy: RegisterSelector called twice for strategy " + name)
+	}
+	selectors[name] = factory
+}
+
+// Strategies returns the names of all registered strategies
+func Strategies() []string {
+	selectorsMu.RLock()
+	defer selectorsMu.RUnlock()
+
+	names := make([]string, 0, len(selectors))
+	for name := range selectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateStrategy checks that a strategy is registered, an empty strategy picks the default
+func ValidateStrategy(name string) error {
+	if name == "" {
+		return nil
+	}
+	selectorsMu.RLock()
+	defer selectorsMu.RUnlock()
+	if _, ok := selectors[name]; !ok {
+		return fmt.Errorf("unknown strategy: %s", name)
+	}
+	return nil
+}
+
+// strategy returns the configured strategy, or the one implied by the rest of the
+// configuration: condition if a condition is set, hash for hash assignment and
+// weighted random otherwise
+func (p *Proxy) strategy() string {
+	switch {
+	case p.Config.Strategy != "":
+		return p.Config.Strategy
+	case p.Config.Condition != nil:
+		return StrategyCondition
+	case p.isHashAssignment():
+		return StrategyHash
+	default:
+		return StrategyWeightedRandom
+	}
+}
+
+// sticky reports whether users are kept on their target with a cookie. Only the
+// random strategies need it, the others pick the same target again on their own
+// or are meant to decide on every request
+func (p *Proxy) sticky() bool {
+	switch p.strategy() {
+	case StrategyWeightedRandom, StrategyBandit:
+		return true
+	}
+	return false
+}
+
+func newSelector(p *Proxy) (Selector, error) {
+	name := p.strategy()
+
+	selectorsMu.RLock()
+	factory, ok := selectors[name]
+	selectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy: %s", name)
+	}
+	return factory(p)
+}
+
+// IsBandit reports whether the supervisor should reweight the targets of the proxy
+func (c *Config) IsBandit() bool {
+	return c.Strategy == StrategyBandit || (c.Bandit != nil && c.Bandit.Enabled)
+}
+
+// weightedSelector maps a point in [0, 1) onto the cumulative target weights
+type weightedSelector struct {
+	point func(r *http.Request, info *RedirectInfo) float64
+}
+
+func randomPoint(*http.Request, *RedirectInfo) float64 {
+	return rand.Float64()
+}
+
+func (p *Proxy) hashPoint(r *http.Request, info *RedirectInfo) float64 {
+	return hashPoint(p.assignmentSalt(), p.identity(r, info))
+}
+
+func (s weightedSelector) Select(r *http.Request, info *RedirectInfo, targets []Target) (*Target, error) {
+	return s.selectFrom(r, info, newTargetTable(targets))
+}
+
+func (s weightedSelector) selectFrom(r *http.Request, info *RedirectInfo, table *targetTable) (*Target, error) {
+	if len(table.targets) == 0 {
+		return nil, fmt.Errorf("no active targets available")
+	}
+	return table.pick(s.point(r, info)), nil
+}
+
+// roundRobinSelector cycles through the active targets, ignoring their weights
+type roundRobinSelector struct {
+	next atomic.Uint64
+}
+
+func (s *roundRobinSelector) Select(_ *http.Request, _ *RedirectInfo, targets []Target) (*Target, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no active targets available")
+	}
+	n := s.next.Add(1) - 1
+	return &targets[n%uint64(len(targets))], nil
+}
+
+// latencySmoothing is the weight of the newest sample in the moving average
+const latencySmoothing = 0.3
+
+// leastLatencySelector sends requests to the target with the lowest moving average
+// latency, targets without samples are tried first
+type leastLatencySelector struct {
+	mu      sync.Mutex
+	latency map[string]float64
+}
+
+func newLeastLatencySelector() *leastLatencySelector {
+	return &leastLatencySelector{latency: make(map[string]float64)}
+}
+
+func (s *leastLatencySelector) Select(_ *http.Request, _ *RedirectInfo, targets []Target) (*Target, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no active targets available")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := 0
+	bestLatency, observed := s.latency[targets[0].ID]
+	for i := 1; i < len(targets) && observed; i++ {
+		latency, ok := s.latency[targets[i].ID]
+		if !ok || latency < bestLatency {
+			best, bestLatency, observed = i, latency, ok
+		}
+	}
+	return &targets[best], nil
+}
+
+func (s *leastLatencySelector) ObserveLatency(targetID string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sample := d.Seconds()
+	if current, ok := s.latency[targetID]; ok {
+		sample = latencySmoothing*sample + (1-latencySmoothing)*current
+	}
+	s.latency[targetID] = sample
+}
+
+// conditionSelector routes by the proxy's condition and rules
+type conditionSelector struct {
+	proxy *Proxy
+}
+
+func newConditionSelector(p *Proxy) (Selector, error) {
+	if p.Config.Condition == nil {
+		return nil, fmt.Errorf("condition strategy requires a condition")
+	}
+	return conditionSelector{proxy: p}, nil
+}
+
+func (s conditionSelector) Select(r *http.Request, _ *RedirectInfo, _ []Target) (*Target, error) {
+	if target := s.proxy.getTargetByCondition(r); target != nil {
+		return target, nil
+	}
+	return nil, fmt.Errorf("no matching target found")
+}
+
+// observeLatency reports the duration of a request to the selector if it tracks latency
+func (p *Proxy) observeLatency(targetID string, d time.Duration) {
+	if observer, ok := p.selector.(LatencyObserver); ok {
+		observer.ObserveLatency(targetID, d)
+	}
+}
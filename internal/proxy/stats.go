@@ -1,24 +1,69 @@
 package proxy
 
 import (
+	"net/http"
 	"sync"
 	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// Cohort tells how a request was assigned to its target
+type Cohort string
+
+const (
+	// CohortExposed requests take part in the experiment
+	CohortExposed Cohort = "exposed"
+	// CohortLayerExcluded requests belong to another experiment of the proxy's layer
+	CohortLayerExcluded Cohort = "layer_excluded"
+	// CohortHoldout requests come from the global holdout group
+	CohortHoldout Cohort = "holdout"
+	// CohortOverride requests were forced to a target for QA or preview
+	CohortOverride Cohort = "override"
+	// CohortAnonymous requests come from users who have not given consent
+	CohortAnonymous Cohort = "anonymous"
 )
 
 type TargetStats struct {
+	RequestCount     int64 // Exposed requests only
+	ErrorCount       int64
+	ServerErrorCount int64            // Responses with a 5xx status
+	Latency          []int64          // Responses per latency bucket, see LatencyBuckets
+	Cohorts          map[Cohort]int64 // Requests served outside of the experiment
+	LastUpdated      time.Time
+}
+
+// ShadowStats are the outcomes of requests mirrored to a shadow target
+type ShadowStats struct {
 	RequestCount int64
-	ErrorCount   int64
+	ErrorCount   int64         // Requests that got no response
+	StatusCodes  map[int]int64 // Responses by status code
+	TotalLatency time.Duration
 	LastUpdated  time.Time
 }
 
+// maxDiffExamples bounds the mismatch examples kept per shadow target between flushes
+const maxDiffExamples = 10
+
+// DiffStats are the results of comparing shadow responses with the primary ones
+type DiffStats struct {
+	Compared   int64
+	Mismatched int64
+	Examples   []models.ResponseDiff
+}
+
 type Stats struct {
 	mu      sync.RWMutex
 	Targets map[string]*TargetStats // key is target ID
+	Shadows map[string]*ShadowStats // key is shadow target URL
+	Diffs   map[string]*DiffStats   // key is shadow target URL
 }
 
 func NewProxyStats() *Stats {
 	return &Stats{
 		Targets: make(map[string]*TargetStats),
+		Shadows: make(map[string]*ShadowStats),
+		Diffs:   make(map[string]*DiffStats),
 	}
 }
 
@@ -33,6 +78,27 @@ func (s *Stats) IncrementRequests(targetID string) {
 	s.Targets[targetID].LastUpdated = time.Now()
 }
 
+// IncrementExposure counts a request for the experiment if it is exposed,
+// other cohorts are counted separately
+func (s *Stats) IncrementExposure(targetID string, cohort Cohort) {
+	if cohort == CohortExposed {
+		s.IncrementRequests(targetID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.Targets[targetID]; !exists {
+		s.Targets[targetID] = &TargetStats{}
+	}
+	if s.Targets[targetID].Cohorts == nil {
+		s.Targets[targetID].Cohorts = make(map[Cohort]int64)
+	}
+	s.Targets[targetID].Cohorts[cohort]++
+	s.Targets[targetID].LastUpdated = time.Now()
+}
+
 func (s *Stats) IncrementErrors(targetID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -44,6 +110,101 @@ func (s *Stats) IncrementErrors(targetID string) {
 	s.Targets[targetID].LastUpdated = time.Now()
 }
 
+// RecordResponse records the status and latency of a reverse proxied response
+func (s *Stats) RecordResponse(targetID string, status int, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.Targets[targetID]
+	if !exists {
+		stats = &TargetStats{}
+		s.Targets[targetID] = stats
+	}
+	if status >= http.StatusInternalServerError {
+		stats.ServerErrorCount++
+	}
+	if stats.Latency == nil {
+		stats.Latency = make([]int64, len(LatencyBuckets)+1)
+	}
+	stats.Latency[latencyBucket(latency)]++
+	stats.LastUpdated = time.Now()
+}
+
+// RecordShadow counts a mirrored request, status is 0 if no response was received
+func (s *Stats) RecordShadow(shadow string, status int, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.Shadows[shadow]
+	if !exists {
+		stats = &ShadowStats{StatusCodes: make(map[int]int64)}
+		s.Shadows[shadow] = stats
+	}
+	stats.RequestCount++
+	if status == 0 {
+		stats.ErrorCount++
+	} else {
+		stats.StatusCodes[status]++
+	}
+	stats.TotalLatency += latency
+	stats.LastUpdated = time.Now()
+}
+
+// RecordDiff counts a comparison and keeps the first mismatches as examples
+func (s *Stats) RecordDiff(shadow string, diff models.ResponseDiff, mismatch bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.Diffs[shadow]
+	if !exists {
+		stats = &DiffStats{}
+		s.Diffs[shadow] = stats
+	}
+	stats.Compared++
+	if mismatch {
+		stats.Mismatched++
+		if len(stats.Examples) < maxDiffExamples {
+			stats.Examples = append(stats.Examples, diff)
+		}
+	}
+}
+
+func (s *Stats) GetDiffStats() map[string]*DiffStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[string]*DiffStats, len(s.Diffs))
+	for shadow, diffStats := range s.Diffs {
+		stats[shadow] = &DiffStats{
+			Compared:   diffStats.Compared,
+			Mismatched: diffStats.Mismatched,
+			Examples:   append([]models.ResponseDiff(nil), diffStats.Examples...),
+		}
+	}
+	return stats
+}
+
+func (s *Stats) GetShadowStats() map[string]*ShadowStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[string]*ShadowStats, len(s.Shadows))
+	for shadow, shadowStats := range s.Shadows {
+		statusCodes := make(map[int]int64, len(shadowStats.StatusCodes))
+		for status, count := range shadowStats.StatusCodes {
+			statusCodes[status] = count
+		}
+		stats[shadow] = &ShadowStats{
+			RequestCount: shadowStats.RequestCount,
+			ErrorCount:   shadowStats.ErrorCount,
+			StatusCodes:  statusCodes,
+			TotalLatency: shadowStats.TotalLatency,
+			LastUpdated:  shadowStats.LastUpdated,
+		}
+	}
+	return stats
+}
+
 func (s *Stats) GetStats() map[string]*TargetStats {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -52,9 +213,17 @@ func (s *Stats) GetStats() map[string]*TargetStats {
 	stats := make(map[string]*TargetStats)
 	for id, target := range s.Targets {
 		stats[id] = &TargetStats{
-			RequestCount: target.RequestCount,
-			ErrorCount:   target.ErrorCount,
-			LastUpdated:  target.LastUpdated,
+			RequestCount:     target.RequestCount,
+			ErrorCount:       target.ErrorCount,
+			ServerErrorCount: target.ServerErrorCount,
+			Latency:          append([]int64(nil), target.Latency...),
+			LastUpdated:      target.LastUpdated,
+		}
+		if len(target.Cohorts) > 0 {
+			stats[id].Cohorts = make(map[Cohort]int64, len(target.Cohorts))
+			for cohort, count := range target.Cohorts {
+				stats[id].Cohorts[cohort] = count
+			}
 		}
 	}
 	return stats
@@ -65,4 +234,6 @@ func (s *Stats) Reset() {
 	defer s.mu.Unlock()
 
 	s.Targets = make(map[string]*TargetStats)
+	s.Shadows = make(map[string]*ShadowStats)
+	s.Diffs = make(map[string]*DiffStats)
 }
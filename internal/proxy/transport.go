@@ -0,0 +1,289 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultIdleTimeout         = 90 * time.Second
+	defaultDialTimeout         = 10 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// transportSettings are the connection settings of a proxy with defaults applied,
+// proxies with equal settings can share upstreams
+type transportSettings struct {
+	maxIdleConns          int
+	idleTimeout           time.Duration
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+}
+
+// upstream is the long-lived reverse proxy of a target with its own connection
+// pool. It keeps no per-request or per-proxy state, so a rebuilt proxy can take
+// over the upstreams of the proxy it replaces along with their connections
+type upstream struct {
+	url       *url.URL
+	transport *http.Transport
+	proxy     *httputil.ReverseProxy
+}
+
+type forwardStateKey struct{}
+
+// forwardState is the state of a reverse proxied request, the shared upstream
+// reverse proxies read it from the request context
+type forwardState struct {
+	proxy   *Proxy
+	request *http.Request // Incoming request, every attempt starts from it
+	info    *RedirectInfo
+	target  *Target // Target of the current attempt
+	retry   *retryState
+	status  int
+}
+
+// bufferPool reuses the buffers the reverse proxies copy response bodies with
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func (b *bufferPool) Get() []byte {
+	if buf, ok := b.pool.Get().(*[]byte); ok {
+		return *buf
+	}
+	return make([]byte, 32<<10)
+}
+
+func (b *bufferPool) Put(buf []byte) {
+	b.pool.Put(&buf)
+}
+
+var upstreamBuffers = &bufferPool{}
+
+// ValidateTransport checks that the connection settings are not negative
+func ValidateTransport(t *models.TransportSettings) error {
+	_, err := compileTransport(t)
+	return err
+}
+
+func compileTransport(t *models.TransportSettings) (transportSettings, error) {
+	settings := transportSettings{
+		maxIdleConns:        defaultMaxIdleConns,
+		idleTimeout:         defaultIdleTimeout,
+		dialTimeout:         defaultDialTimeout,
+		tlsHandshakeTimeout: defaultTLSHandshakeTimeout,
+	}
+	if t == nil {
+		return settings, nil
+	}
+	if t.MaxIdleConns < 0 || t.IdleTimeoutSeconds < 0 || t.DialTimeoutMs < 0 ||
+		t.TLSHandshakeTimeoutMs < 0 || t.ResponseHeaderTimeoutMs < 0 {
+		return settings, errors.New("transport settings must not be negative")
+	}
+
+	if t.MaxIdleConns > 0 {
+		settings.maxIdleConns = t.MaxIdleConns
+	}
+	if t.IdleTimeoutSeconds > 0 {
+		settings.idleTimeout = time.Duration(t.IdleTimeoutSeconds) * time.Second
+	}
+	if t.DialTimeoutMs > 0 {
+		settings.dialTimeout = time.Duration(t.DialTimeoutMs) * time.Millisecond
+	}
+	if t.TLSHandshakeTimeoutMs > 0 {
+		settings.tlsHandshakeTimeout = time.Duration(t.TLSHandshakeTimeoutMs) * time.Millisecond
+	}
+	settings.responseHeaderTimeout = time.Duration(t.ResponseHeaderTimeoutMs) * time.Millisecond
+	return settings, nil
+}
+
+func newUpstream(targetURL string, settings transportSettings) (*upstream, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   settings.dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          settings.maxIdleConns,
+		MaxIdleConnsPerHost:   settings.maxIdleConns,
+		IdleConnTimeout:       settings.idleTimeout,
+		TLSHandshakeTimeout:   settings.tlsHandshakeTimeout,
+		ResponseHeaderTimeout: settings.responseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		state, _ := r.Context().Value(forwardStateKey{}).(*forwardState)
+		state.rewritePath(r)
+		director(r)
+		state.rewriteHeaders(r)
+	}
+	proxy.Transport = transport
+	proxy.BufferPool = upstreamBuffers
+	proxy.ModifyResponse = modifyUpstreamResponse
+	proxy.ErrorHandler = handleUpstreamError
+
+	return &upstream{url: u, transport: transport, proxy: proxy}, nil
+}
+
+// buildUpstreams returns the upstreams of the targets, reusing the current ones
+// of targets whose URL is unchanged. Targets with an invalid URL get none
+func buildUpstreams(targets []Target, current map[string]*upstream, settings transportSettings) map[string]*upstream {
+	upstreams := make(map[string]*upstream, len(targets))
+	for _, target := range targets {
+		if up, ok := current[target.ID]; ok && up.url.String() == target.URL {
+			upstreams[target.ID] = up
+			continue
+		}
+		if up, err := newUpstream(target.URL, settings); err == nil {
+			upstreams[target.ID] = up
+		}
+	}
+	return upstreams
+}
+
+// closeUnused closes the idle connections of the upstreams that are no longer used
+func closeUnused(previous, current map[string]*upstream) {
+	for id, up := range previous {
+		if current[id] != up {
+			up.transport.CloseIdleConnections()
+		}
+	}
+}
+
+// ReuseUpstreams takes over the upstreams and open connections of the proxy this
+// one replaces, for targets whose URL and connection settings did not change
+func (p *Proxy) ReuseUpstreams(previous *Proxy) {
+	if previous == nil || previous == p {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	old, current := previous.snapshot(), p.snapshot()
+	if previous.transport == p.transport {
+		next := *current
+		next.upstreams = buildUpstreams(current.targets, old.upstreams, p.transport)
+		p.routing.Store(&next)
+		current = &next
+	}
+	closeUnused(old.upstreams, current.upstreams)
+}
+
+// Close releases the idle connections to the targets of a proxy that is removed
+func (p *Proxy) Close() {
+	for _, up := range p.snapshot().upstreams {
+		up.transport.CloseIdleConnections()
+	}
+}
+
+func (p *Proxy) upstream(targetID string) *upstream {
+	return p.snapshot().upstreams[targetID]
+}
+
+// forward reverse proxies the request to the target and retries it on another
+// attempt when the connection fails. It returns the target that served the
+// response and its status code, 0 if no target responded
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, info *RedirectInfo, target *Target, retry *retryState) (*Target, int) {
+	state := &forwardState{proxy: p, info: info, target: target, retry: retry}
+	state.request = r.WithContext(context.WithValue(r.Context(), forwardStateKey{}, state))
+	p.attempt(w, state)
+	return state.target, state.status
+}
+
+// attempt sends the request to the target of the current attempt
+func (p *Proxy) attempt(w http.ResponseWriter, state *forwardState) {
+	up := p.upstream(state.target.ID)
+	if up == nil {
+		http.Error(w, "Invalid target URL", http.StatusInternalServerError)
+		return
+	}
+	state.request.Header.Set("X-Redirect-Query-Params", up.url.RawQuery)
+	state.retry.rewind(state.request)
+	up.proxy.ServeHTTP(w, state.request)
+}
+
+// headerVars returns the values for the header rules of the current attempt
+func (s *forwardState) headerVars() headerVars {
+	vars := headerVars{targetID: s.target.ID}
+	if s.info != nil {
+		vars.ruid, vars.rrid, vars.rid = s.info.RUID, s.info.RRID, s.info.RID
+	}
+	return vars
+}
+
+// rewritePath applies the path rewrite of the target before the target path is joined
+func (s *forwardState) rewritePath(r *http.Request) {
+	if s == nil {
+		return
+	}
+	if rewrite := s.proxy.snapshot().paths[s.target.ID]; rewrite != nil {
+		r.URL.Path = rewrite.apply(r.URL.Path)
+		r.URL.RawPath = ""
+	}
+}
+
+// rewriteHeaders applies the header rules of the proxy and then of the target
+func (s *forwardState) rewriteHeaders(r *http.Request) {
+	if s == nil {
+		return
+	}
+	vars := s.headerVars()
+	s.proxy.headers.applyRequest(r, vars)
+	s.proxy.snapshot().headers[s.target.ID].applyRequest(r, vars)
+}
+
+func modifyUpstreamResponse(resp *http.Response) error {
+	state, ok := resp.Request.Context().Value(forwardStateKey{}).(*forwardState)
+	if !ok {
+		return nil
+	}
+	state.status = resp.StatusCode
+	state.proxy.recordOutcome(state.target.ID, resp.StatusCode < http.StatusInternalServerError)
+
+	vars := state.headerVars()
+	state.proxy.headers.applyResponse(resp, vars)
+	state.proxy.snapshot().headers[state.target.ID].applyResponse(resp, vars)
+	return nil
+}
+
+// handleUpstreamError retries failed requests when the policy allows it. The
+// outgoing request it gets is already rewritten for the failed target, retries
+// start again from the incoming request
+func handleUpstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	state, ok := r.Context().Value(forwardStateKey{}).(*forwardState)
+	if !ok {
+		http.Error(w, "Error forwarding request", http.StatusBadGateway)
+		return
+	}
+
+	p := state.proxy
+	p.stats.IncrementErrors(state.target.ID)
+	p.recordOutcome(state.target.ID, false)
+	if next := p.retryTarget(state.request, state.target, state.retry); next != nil {
+		state.target = next
+		p.attempt(w, state)
+		return
+	}
+	http.Error(w, "Error forwarding request", http.StatusBadGateway)
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+func newBenchmarkProxy(b *testing.B) *Proxy {
+	b.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	b.Cleanup(backend.Close)
+
+	p, err := NewProxy(Config{
+		ID:        "bench",
+		ListenURL: "bench.local:80",
+		Mode:      models.ProxyModeReverse,
+		Targets: []Target{
+			{ID: "a", URL: backend.URL, Weight: 0.5, IsActive: true},
+			{ID: "b", URL: backend.URL + "/b", Weight: 0.5, IsActive: true},
+		},
+		Transport: &models.TransportSettings{MaxIdleConns: 256},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(p.Close)
+	return p
+}
+
+// runParallel sends requests to h from many goroutines, more than the two idle
+// connections per host the default transport keeps
+func runParallel(b *testing.B, h http.Handler) {
+	b.ReportAllocs()
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://bench.local/", nil))
+			if w.Code != http.StatusOK {
+				b.Errorf("unexpected status %d", w.Code)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkServeHTTPReverse measures the whole reverse proxy path including selection
+func BenchmarkServeHTTPReverse(b *testing.B) {
+	runParallel(b, newBenchmarkProxy(b))
+}
+
+// BenchmarkForward compares the long-lived upstream of a target with creating a
+// reverse proxy on the default transport for every request
+func BenchmarkForward(b *testing.B) {
+	b.Run("upstream", func(b *testing.B) {
+		p := newBenchmarkProxy(b)
+		target := p.Targets()[0]
+		runParallel(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p.forward(w, r, nil, &target, nil)
+		}))
+	})
+
+	b.Run("per_request", func(b *testing.B) {
+		p := newBenchmarkProxy(b)
+		target := p.Targets()[0]
+		targetURL, err := url.Parse(target.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		runParallel(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxy := httputil.NewSingleHostReverseProxy(targetURL)
+			proxy.ModifyResponse = func(resp *http.Response) error {
+				p.recordOutcome(target.ID, resp.StatusCode < http.StatusInternalServerError)
+				return nil
+			}
+			proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+				http.Error(w, "Error forwarding request", http.StatusBadGateway)
+			}
+			proxy.ServeHTTP(w, r)
+		}))
+	})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecordConversionRequest struct {
+	TargetID string `json:"target_id" binding:"required"`
+	Count    int64  `json:"count"`
+}
+
+// recordConversion counts successes for a target of a proxy running in bandit mode
+func (s *Server) recordConversion(c *gin.Context) {
+	proxyID := c.Param("id")
+
+	var req RecordConversionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Count == 0 {
+		req.Count = 1
+	}
+	if req.Count < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be positive"})
+		return
+	}
+
+	if !s.checkProxyTarget(c, proxyID, req.TargetID) {
+		return // Error already sent to client
+	}
+
+	if err := s.storage.AddBanditConversions(c.Request.Context(), proxyID, req.TargetID, req.Count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// checkProxyTarget verifies that the running proxy has the target
+func (s *Server) checkProxyTarget(c *gin.Context, proxyID, targetID string) bool {
+	p := s.supervisor.GetProxy(proxyID)
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proxy not found"})
+		return false
+	}
+
+	for _, target := range p.Targets() {
+		if target.ID == targetID {
+			return true
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "target does not belong to proxy"})
+	return false
+}
+
+func (s *Server) getBanditCounters(c *gin.Context) {
+	counters, err := s.storage.GetBanditCounters(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"counters": counters})
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+)
+
+// RouteRule routes requests matching the rule tree to a target of the request.
+// Targets get their IDs on creation, so rules refer to them by position.
+type RouteRule struct {
+	TargetIndex int             `json:"target_index"`
+	Match       models.RuleNode `json:"match"`
+}
+
+func validateRouteRules(rules []RouteRule, targetCount int) error {
+	for i, rule := range rules {
+		if rule.TargetIndex < 0 || rule.TargetIndex >= targetCount {
+			return fmt.Errorf("rule %d: target_index %d is out of range", i, rule.TargetIndex)
+		}
+		if err := proxy.ValidateRule(&rule.Match); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateConditionOperator rejects unknown operators and values that do not compile, e.g. a bad regex
+func validateConditionOperator(condition *RouteCondition) error {
+	op := models.MatchOperator(condition.Operator)
+	if op != "" && !op.IsValid() {
+		return errors.New("invalid condition operator")
+	}
+	return proxy.ValidateMatch(models.ConditionType(condition.Type), op, condition.Values)
+}
+
+func convertRouteRules(rules []RouteRule, targets []models.Target) []models.RouteRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	converted := make([]models.RouteRule, len(rules))
+	for i, rule := range rules {
+		converted[i] = models.RouteRule{
+			TargetID: targets[rule.TargetIndex].ID,
+			Match:    rule.Match,
+		}
+	}
+	return converted
+}
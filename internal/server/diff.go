@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetProxyDiffsRequest struct {
+	Limit int `form:"limit,default=20"`
+}
+
+// getProxyDiffs returns the mismatch counts between primary and shadow responses
+// and the most recent examples
+func (s *Server) getProxyDiffs(c *gin.Context) {
+	var req GetProxyDiffsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate and cap the limit
+	if req.Limit <= 0 {
+		req.Limit = 20
+	} else if req.Limit > 100 {
+		req.Limit = 100
+	}
+
+	summaries, examples, err := s.storage.GetDiffs(c.Request.Context(), c.Param("id"), req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":  summaries,
+		"examples": examples,
+	})
+}
+
+func (s *Server) resetProxyDiffs(c *gin.Context) {
+	if err := s.storage.ResetDiffs(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getProxyHealth returns the active health check and outlier detection state of
+// a proxy's targets as seen by this instance. Targets without a health check or
+// without errors are not listed
+func (s *Server) getProxyHealth(c *gin.Context) {
+	proxyID := c.Param("id")
+	if s.supervisor.GetProxy(proxyID) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proxy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"targets":   s.supervisor.Health().Status(proxyID),
+		"ejections": s.supervisor.Outliers().Status(proxyID),
+	})
+}
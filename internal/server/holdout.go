@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getHoldout(c *gin.Context) {
+	holdout := s.supervisor.Holdout()
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    holdout.Percentage() > 0,
+		"percentage": holdout.Percentage(),
+	})
+}
+
+// getHoldoutMembership tells whether the user with the given RUID is in the holdout
+func (s *Server) getHoldoutMembership(c *gin.Context) {
+	ruid := c.Param("ruid")
+
+	c.JSON(http.StatusOK, gin.H{
+		"ruid":    ruid,
+		"holdout": s.supervisor.Holdout().Contains(ruid),
+	})
+}
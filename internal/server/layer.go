@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/storage"
+)
+
+type LayerRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+func (s *Server) listLayers(c *gin.Context) {
+	layers, err := s.storage.GetLayers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"layers": layers, "buckets": models.LayerBuckets})
+}
+
+func (s *Server) createLayer(c *gin.Context) {
+	var req LayerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	layer := &models.Layer{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := s.storage.CreateLayer(c.Request.Context(), layer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, layer)
+}
+
+func (s *Server) getLayer(c *gin.Context) {
+	layer, err := s.storage.GetLayer(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(layerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, layer)
+}
+
+func (s *Server) updateLayer(c *gin.Context) {
+	var req LayerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	layer := &models.Layer{
+		ID:          c.Param("id"),
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := s.storage.UpdateLayer(c.Request.Context(), layer); err != nil {
+		c.JSON(layerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	s.getLayer(c)
+}
+
+func (s *Server) deleteLayer(c *gin.Context) {
+	if err := s.storage.DeleteLayer(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(layerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// layerErrorStatus maps layer storage errors to HTTP status codes
+func layerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrLayerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrLayerInUse), errors.Is(err, storage.ErrLayerRangeTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+	"github.com/ab-testing-service/internal/storage"
+)
+
+// defaultOverrideTTL is how long a signed override token is valid if no TTL is given
+const defaultOverrideTTL = 24 * time.Hour
+
+type OverrideRequest struct {
+	Identity string `json:"identity" binding:"required"` // RUID or the value of the proxy's identity source
+	TargetID string `json:"target_id" binding:"required"`
+	Note     string `json:"note"`
+}
+
+type SignOverrideRequest struct {
+	TargetID string `json:"target_id" binding:"required"`
+	TTL      string `json:"ttl"` // Go duration, e.g. "2h"
+}
+
+func (s *Server) listOverrides(c *gin.Context) {
+	overrides, err := s.storage.GetOverrides(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"overrides": overrides})
+}
+
+func (s *Server) createOverride(c *gin.Context) {
+	proxyID := c.Param("id")
+
+	var req OverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.checkProxyTarget(c, proxyID, req.TargetID) {
+		return // Error already sent to client
+	}
+
+	override := &models.Override{
+		ProxyID:   proxyID,
+		Identity:  req.Identity,
+		TargetID:  req.TargetID,
+		Note:      req.Note,
+		CreatedBy: s.getUserID(c),
+	}
+	if err := s.storage.CreateOverride(c.Request.Context(), override); err != nil {
+		c.JSON(overrideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if !s.reloadProxy(c, proxyID) {
+		return // Error already sent to client
+	}
+
+	c.JSON(http.StatusCreated, override)
+}
+
+func (s *Server) updateOverride(c *gin.Context) {
+	proxyID := c.Param("id")
+
+	var req OverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.checkProxyTarget(c, proxyID, req.TargetID) {
+		return // Error already sent to client
+	}
+
+	override := &models.Override{
+		ID:       c.Param("override_id"),
+		ProxyID:  proxyID,
+		Identity: req.Identity,
+		TargetID: req.TargetID,
+		Note:     req.Note,
+	}
+	if err := s.storage.UpdateOverride(c.Request.Context(), override); err != nil {
+		c.JSON(overrideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if !s.reloadProxy(c, proxyID) {
+		return // Error already sent to client
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+func (s *Server) deleteOverride(c *gin.Context) {
+	proxyID := c.Param("id")
+
+	if err := s.storage.DeleteOverride(c.Request.Context(), proxyID, c.Param("override_id")); err != nil {
+		c.JSON(overrideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if !s.reloadProxy(c, proxyID) {
+		return // Error already sent to client
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// signOverride creates a token for the ab_force query parameter or X-AB-Force header
+func (s *Server) signOverride(c *gin.Context) {
+	proxyID := c.Param("id")
+
+	var req SignOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := defaultOverrideTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a positive duration"})
+			return
+		}
+	}
+
+	if !s.checkProxyTarget(c, proxyID, req.TargetID) {
+		return // Error already sent to client
+	}
+
+	secret, err := s.storage.GetOverrideSecret(c.Request.Context(), proxyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	c.JSON(http.StatusOK, gin.H{
+		"token":      proxy.SignOverride(secret, proxyID, req.TargetID, expires),
+		"param":      proxy.OverrideParam,
+		"header":     proxy.OverrideHeader,
+		"expires_at": expires,
+	})
+}
+
+// reloadProxy applies changed overrides to the running proxy on all instances
+func (s *Server) reloadProxy(c *gin.Context, proxyID string) bool {
+	if err := s.supervisor.ReloadProxy(c.Request.Context(), proxyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// overrideErrorStatus maps override storage errors to HTTP status codes
+func overrideErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrOverrideNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrOverrideExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -11,24 +11,61 @@ import (
 )
 
 type RouteCondition struct {
-	Type      string   `json:"type" db:"type"`        // Type of condition: "header", "query", "cookie", "user_agent", "language"
-	ParamName string   `json:"param_name" db:"param"` // Name of the parameter to check (for header, query, cookie)
-	Values    []string `json:"values" db:"values"`    // List of parameter values to match targets
-	Default   string   `json:"default" db:"default"`  // Default target ID if no match is found
+	Type      string      `json:"type" db:"type"`        // Type of condition: "header", "query", "cookie", "user_agent", "language"
+	ParamName string      `json:"param_name" db:"param"` // Name of the parameter to check (for header, query, cookie)
+	Operator  string      `json:"operator,omitempty"`    // How values are compared, "eq" by default
+	Values    []string    `json:"values" db:"values"`    // List of parameter values to match targets
+	Default   string      `json:"default" db:"default"`  // Default target ID if no match is found
+	Rules     []RouteRule `json:"rules,omitempty"`       // Rule trees evaluated in order before the single condition
 }
 
 type CreateProxyRequest struct {
-	ListenURL string             `json:"listen_url" binding:"required"`
-	Mode      string             `json:"mode" binding:"required"`
-	Tags      []string           `json:"tags"`
-	Targets   []CreateTargetSpec `json:"targets"`
-	Condition *RouteCondition    `json:"condition,omitempty"`
+	ListenURL  string             `json:"listen_url" binding:"required"`
+	Mode       string             `json:"mode" binding:"required"`
+	Tags       []string           `json:"tags"`
+	Targets    []CreateTargetSpec `json:"targets"`
+	Condition  *RouteCondition    `json:"condition,omitempty"`
+	Assignment *models.Assignment `json:"assignment,omitempty"`
+	// CIDRs of proxies allowed to set X-Forwarded-For and X-Real-IP
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	// When the experiment runs, outside of it all traffic goes to the default target
+	Schedule *models.Schedule `json:"schedule,omitempty"`
+	// Automatic reweighting towards the best converting target
+	Bandit *models.BanditSettings `json:"bandit,omitempty"`
+	// Target selection strategy, derived from the condition and assignment when empty
+	Strategy string `json:"strategy,omitempty"`
+	// Bucket range in a layer of mutually exclusive experiments
+	Layer *models.LayerAllocation `json:"layer,omitempty"`
+	// Replay a sample of requests to shadow targets
+	Mirror *models.MirrorSettings `json:"mirror,omitempty"`
+	// Roll a target back when its error rate or latency regresses
+	Guardrails *models.GuardrailSettings `json:"guardrails,omitempty"`
+	// Eject targets after consecutive errors
+	OutlierDetection *models.OutlierDetection `json:"outlier_detection,omitempty"`
+	// Retry requests whose upstream connection failed
+	Retry *models.RetryPolicy `json:"retry,omitempty"`
+	// Connection settings for the targets
+	Transport *models.TransportSettings `json:"transport,omitempty"`
+	// Header rewrite rules for all targets
+	Headers *models.HeaderRules `json:"headers,omitempty"`
+	// Sticky assignment cookie settings
+	Cookie *models.CookiePolicy `json:"cookie,omitempty"`
+	// Consent signal required before identifier cookies are set
+	Consent *models.ConsentPolicy `json:"consent,omitempty"`
 }
 
 type CreateTargetSpec struct {
 	URL      string  `json:"url" binding:"required"`
 	Weight   float64 `json:"weight" binding:"required,min=0,max=1"`
 	IsActive bool    `json:"is_active"`
+	// Users who are not part of the experiment are sent to the control target
+	IsControl bool `json:"is_control"`
+	// Active health check, unhealthy targets get no new users until they recover
+	HealthCheck *models.HealthCheck `json:"health_check,omitempty"`
+	// Header rewrite rules applied after the rules of the proxy
+	Headers *models.HeaderRules `json:"headers,omitempty"`
+	// Maps the request path onto the path of the target
+	PathRewrite *models.PathRewrite `json:"path_rewrite,omitempty"`
 }
 
 func (s *Server) createProxy(c *gin.Context) {
@@ -49,11 +86,126 @@ func (s *Server) createProxy(c *gin.Context) {
 		return
 	}
 
+	if err := validateAssignment(req.Assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidatePrefixes(req.TrustedProxies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateSchedule(req.Schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateBandit(req.Bandit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateStrategy(req.Strategy, req.Condition != nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateStrategyMode(req.Strategy, models.ProxyMode(req.Mode)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Layer != nil {
+		if err := req.Layer.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := proxy.ValidateMirror(req.Mirror); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateGuardrails(req.Guardrails); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateOutlierDetection(req.OutlierDetection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateRetry(req.Retry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateTransport(req.Transport); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateHeaderRules(req.Headers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateCookiePolicy(req.Cookie); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := proxy.ValidateConsent(req.Consent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	controls := 0
+	for _, t := range req.Targets {
+		if t.IsControl {
+			controls++
+		}
+		if err := proxy.ValidateHealthCheck(t.HealthCheck); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := proxy.ValidateHeaderRules(t.Headers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := proxy.ValidatePathRewrite(t.PathRewrite); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := validateControlTargets(controls); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create proxy model
 	p := &models.Proxy{
-		ListenURL: req.ListenURL,
-		Mode:      req.Mode,
-		Tags:      req.Tags,
+		ListenURL:  req.ListenURL,
+		Mode:       req.Mode,
+		Tags:       req.Tags,
+		Assignment: req.Assignment,
+
+		TrustedProxies:   req.TrustedProxies,
+		Schedule:         req.Schedule,
+		Bandit:           req.Bandit,
+		Strategy:         req.Strategy,
+		Layer:            req.Layer,
+		Mirror:           req.Mirror,
+		Guardrails:       req.Guardrails,
+		OutlierDetection: req.OutlierDetection,
+		Retry:            req.Retry,
+		Transport:        req.Transport,
+		Headers:          req.Headers,
+		Cookie:           req.Cookie,
+		Consent:          req.Consent,
 	}
 
 	// Convert targets
@@ -65,18 +217,38 @@ func (s *Server) createProxy(c *gin.Context) {
 				URL:      t.URL,
 				Weight:   t.Weight,
 				IsActive: t.IsActive,
+
+				IsControl:   t.IsControl,
+				HealthCheck: t.HealthCheck,
+				Headers:     t.Headers,
+				PathRewrite: t.PathRewrite,
 			}
 		}
 	}
 
+	if err := validateFallbackTarget(req.OutlierDetection, p.Targets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Convert condition
-	if req.Condition.Type != "" {
+	if req.Condition != nil && (req.Condition.Type != "" || len(req.Condition.Rules) > 0) {
 		conditionType := models.ConditionType(req.Condition.Type)
-		if !conditionType.IsValid() {
+		if conditionType != "" && !conditionType.IsValid() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid condition type"})
 			return
 		}
 
+		if err := validateConditionOperator(req.Condition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validateRouteRules(req.Condition.Rules, len(p.Targets)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		conditionValues := make(map[string]string, len(req.Condition.Values))
 		for i, v := range req.Condition.Values {
 			conditionValues[p.Targets[i].ID] = v
@@ -85,14 +257,16 @@ func (s *Server) createProxy(c *gin.Context) {
 		p.Condition = &models.RouteCondition{
 			Type:      conditionType,
 			ParamName: req.Condition.ParamName,
+			Operator:  models.MatchOperator(req.Condition.Operator),
 			Values:    conditionValues,
 			Default:   req.Condition.Default,
+			Rules:     convertRouteRules(req.Condition.Rules, p.Targets),
 		}
 	}
 
 	// Create proxy in storage -> postgres
 	if err := s.storage.CreateProxy(c.Request.Context(), p); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(layerErrorStatus(err), gin.H{
 			"error":   "failed to create proxy in storage",
 			"details": err.Error(),
 		})
@@ -101,22 +275,29 @@ func (s *Server) createProxy(c *gin.Context) {
 
 	// Create proxy configuration for supervisor
 	cfg := proxy.Config{
-		ID:        p.ID,
-		ListenURL: p.ListenURL,
-		Mode:      models.ProxyMode(p.Mode),
+		ID:         p.ID,
+		ListenURL:  p.ListenURL,
+		Mode:       models.ProxyMode(p.Mode),
+		Assignment: p.Assignment,
+
+		TrustedProxies:   p.TrustedProxies,
+		Schedule:         p.Schedule,
+		Bandit:           p.Bandit,
+		Strategy:         p.Strategy,
+		Layer:            p.Layer,
+		Mirror:           p.Mirror,
+		Guardrails:       p.Guardrails,
+		OutlierDetection: p.OutlierDetection,
+		Retry:            p.Retry,
+		Transport:        p.Transport,
+		Headers:          p.Headers,
+		Cookie:           p.Cookie,
+		Consent:          p.Consent,
 	}
 
 	// Convert targets to config format
 	if len(p.Targets) > 0 {
-		cfg.Targets = make([]proxy.Target, len(p.Targets))
-		for i, t := range p.Targets {
-			cfg.Targets[i] = proxy.Target{
-				ID:       t.ID,
-				URL:      t.URL,
-				Weight:   t.Weight,
-				IsActive: t.IsActive,
-			}
-		}
+		cfg.Targets = s.convertToConfigTargets(p.Targets)
 	}
 
 	// Add condition if provided
@@ -124,8 +305,10 @@ func (s *Server) createProxy(c *gin.Context) {
 		cfg.Condition = &proxy.Condition{
 			Type:      p.Condition.Type,
 			ParamName: p.Condition.ParamName,
+			Operator:  p.Condition.Operator,
 			Values:    p.Condition.Values,
 			Default:   p.Condition.Default,
+			Rules:     p.Condition.Rules,
 		}
 	}
 
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/storage"
+	"github.com/ab-testing-service/internal/supervisor"
+)
+
+type RolloutRequest struct {
+	TargetID string               `json:"target_id" binding:"required"` // Canary target that is ramped up
+	Steps    []models.RolloutStep `json:"steps" binding:"required"`
+}
+
+func (s *Server) listRollouts(c *gin.Context) {
+	rollouts, err := s.storage.GetRollouts(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rollouts": rollouts})
+}
+
+// createRollout starts a rollout plan, its first step is applied immediately
+func (s *Server) createRollout(c *gin.Context) {
+	proxyID := c.Param("id")
+
+	var req RolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateRolloutSteps(req.Steps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.checkProxyTarget(c, proxyID, req.TargetID) {
+		return // Error already sent to client
+	}
+	if p := s.supervisor.GetProxy(proxyID); p != nil && p.Config.IsBandit() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weights of a bandit proxy cannot be rolled out"})
+		return
+	}
+
+	rollout := &models.Rollout{
+		ProxyID:   proxyID,
+		TargetID:  req.TargetID,
+		Steps:     req.Steps,
+		CreatedBy: s.getUserID(c),
+	}
+	if err := s.supervisor.StartRollout(c.Request.Context(), rollout); err != nil {
+		c.JSON(rolloutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rollout)
+}
+
+func (s *Server) getRollout(c *gin.Context) {
+	rollout, err := s.storage.GetRollout(c.Request.Context(), c.Param("id"), c.Param("rollout_id"))
+	if err != nil {
+		c.JSON(rolloutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rollout": rollout, "next_step_at": rollout.NextStepAt()})
+}
+
+func (s *Server) pauseRollout(c *gin.Context) {
+	rollout, err := s.supervisor.PauseRollout(c.Request.Context(), c.Param("id"), c.Param("rollout_id"))
+	if err != nil {
+		c.JSON(rolloutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rollout)
+}
+
+func (s *Server) resumeRollout(c *gin.Context) {
+	rollout, err := s.supervisor.ResumeRollout(c.Request.Context(), c.Param("id"), c.Param("rollout_id"))
+	if err != nil {
+		c.JSON(rolloutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rollout)
+}
+
+// abortRollout stops a rollout and sets the weight of its canary target to zero
+func (s *Server) abortRollout(c *gin.Context) {
+	rollout, err := s.supervisor.AbortRollout(c.Request.Context(), c.Param("id"), c.Param("rollout_id"), s.getUserID(c))
+	if err != nil {
+		c.JSON(rolloutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rollout)
+}
+
+// rolloutErrorStatus maps rollout errors to HTTP status codes
+func rolloutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrRolloutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, supervisor.ErrInvalidRollout):
+		return http.StatusBadRequest
+	case errors.Is(err, storage.ErrRolloutActive),
+		errors.Is(err, storage.ErrRolloutChanged),
+		errors.Is(err, supervisor.ErrRolloutNotRunning),
+		errors.Is(err, supervisor.ErrRolloutNotPaused),
+		errors.Is(err, supervisor.ErrRolloutFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
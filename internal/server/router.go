@@ -44,6 +44,33 @@ func (s *Server) setupRouter() {
 		api.DELETE("/proxies/:id", s.deleteProxy)
 		api.PUT("/proxies/:id/targets", s.updateProxyTargets)
 		api.GET("/proxies/:id/changes", s.getProxyChanges)
+		api.POST("/proxies/:id/conversions", s.recordConversion)
+		api.GET("/proxies/:id/bandit", s.getBanditCounters)
+		api.GET("/proxies/:id/health", s.getProxyHealth)
+		api.GET("/proxies/:id/diffs", s.getProxyDiffs)
+		api.DELETE("/proxies/:id/diffs", s.resetProxyDiffs)
+		api.GET("/proxies/:id/overrides", s.listOverrides)
+		api.POST("/proxies/:id/overrides", s.createOverride)
+		api.PUT("/proxies/:id/overrides/:override_id", s.updateOverride)
+		api.DELETE("/proxies/:id/overrides/:override_id", s.deleteOverride)
+		api.POST("/proxies/:id/overrides/sign", s.signOverride)
+		api.GET("/proxies/:id/rollouts", s.listRollouts)
+		api.POST("/proxies/:id/rollouts", s.createRollout)
+		api.GET("/proxies/:id/rollouts/:rollout_id", s.getRollout)
+		api.POST("/proxies/:id/rollouts/:rollout_id/pause", s.pauseRollout)
+		api.POST("/proxies/:id/rollouts/:rollout_id/resume", s.resumeRollout)
+		api.POST("/proxies/:id/rollouts/:rollout_id/abort", s.abortRollout)
+
+		// Global holdout
+		api.GET("/holdout", s.getHoldout)
+		api.GET("/holdout/:ruid", s.getHoldoutMembership)
+
+		// Experiment layers
+		api.GET("/layers", s.listLayers)
+		api.POST("/layers", s.createLayer)
+		api.GET("/layers/:id", s.getLayer)
+		api.PUT("/layers/:id", s.updateLayer)
+		api.DELETE("/layers/:id", s.deleteLayer)
 
 		// Tag management
 		api.GET("/tags", s.getAllTags)
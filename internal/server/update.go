@@ -15,11 +15,69 @@ import (
 
 type UpdateTargetsRequest struct {
 	Targets []struct {
+		// ID of an existing target to keep, targets with the same URL keep their ID as well
+		ID       string  `json:"id,omitempty"`
 		URL      string  `json:"url" binding:"required"`
 		Weight   float64 `json:"weight" binding:"required,min=0,max=1"`
 		IsActive bool    `json:"is_active"`
+		// Users who are not part of the experiment are sent to the control target
+		IsControl bool `json:"is_control"`
+		// Active health check, unhealthy targets get no new users until they recover
+		HealthCheck *models.HealthCheck `json:"health_check,omitempty"`
+		// Header rewrite rules applied after the rules of the proxy
+		Headers *models.HeaderRules `json:"headers,omitempty"`
+		// Maps the request path onto the path of the target
+		PathRewrite *models.PathRewrite `json:"path_rewrite,omitempty"`
 	} `json:"targets"`
-	Condition *RouteCondition `json:"condition,omitempty"`
+	Condition  *RouteCondition    `json:"condition,omitempty"`
+	Assignment *models.Assignment `json:"assignment,omitempty"`
+	// CIDRs of proxies allowed to set X-Forwarded-For and X-Real-IP, an empty list clears them
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	// When the experiment runs, an empty schedule means always
+	Schedule *models.Schedule `json:"schedule,omitempty"`
+	// Automatic reweighting towards the best converting target
+	Bandit *models.BanditSettings `json:"bandit,omitempty"`
+	// Target selection strategy, an empty string restores the default
+	Strategy *string `json:"strategy,omitempty"`
+	// Bucket range in a layer of mutually exclusive experiments, an empty layer_id detaches the proxy
+	Layer *models.LayerAllocation `json:"layer,omitempty"`
+	// Replay a sample of requests to shadow targets, an empty target list turns mirroring off
+	Mirror *models.MirrorSettings `json:"mirror,omitempty"`
+	// Roll a target back when its error rate or latency regresses, zero thresholds turn a rule off
+	Guardrails *models.GuardrailSettings `json:"guardrails,omitempty"`
+	// Eject targets after consecutive errors
+	OutlierDetection *models.OutlierDetection `json:"outlier_detection,omitempty"`
+	// Retry requests whose upstream connection failed
+	Retry *models.RetryPolicy `json:"retry,omitempty"`
+	// Connection settings for the targets
+	Transport *models.TransportSettings `json:"transport,omitempty"`
+	// Header rewrite rules for all targets
+	Headers *models.HeaderRules `json:"headers,omitempty"`
+	// Sticky assignment cookie settings
+	Cookie *models.CookiePolicy `json:"cookie,omitempty"`
+	// Consent signal required before identifier cookies are set
+	Consent *models.ConsentPolicy `json:"consent,omitempty"`
+}
+
+// proxyUpdate holds the new proxy state built from an update request;
+// nil settings are left unchanged
+type proxyUpdate struct {
+	targets          []models.Target
+	condition        *models.RouteCondition
+	assignment       *models.Assignment
+	trustedProxies   []string
+	schedule         *models.Schedule
+	bandit           *models.BanditSettings
+	strategy         *string
+	layer            *models.LayerAllocation
+	mirror           *models.MirrorSettings
+	guardrails       *models.GuardrailSettings
+	outlierDetection *models.OutlierDetection
+	retry            *models.RetryPolicy
+	transport        *models.TransportSettings
+	headers          *models.HeaderRules
+	cookie           *models.CookiePolicy
+	consent          *models.ConsentPolicy
 }
 
 func (s *Server) updateProxyTargets(c *gin.Context) {
@@ -35,14 +93,49 @@ func (s *Server) updateProxyTargets(c *gin.Context) {
 		return // Error already sent to client
 	}
 
-	targets := s.convertToTargetModels(proxyID, req)
-	condition := s.convertToConditionModels(targets, req)
+	if req.Strategy != nil {
+		if err := validateStrategyMode(*req.Strategy, models.ProxyMode(currentProxy.Mode)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	targets := s.convertToTargetModels(proxyID, req, currentProxy)
 
-	if err := s.executeTransaction(c, proxyID, currentProxy, targets, condition); err != nil {
+	outlierDetection := req.OutlierDetection
+	if outlierDetection == nil {
+		outlierDetection = currentProxy.OutlierDetection
+	}
+	if err := validateFallbackTarget(outlierDetection, targets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := proxyUpdate{
+		targets:    targets,
+		condition:  s.convertToConditionModels(targets, req),
+		assignment: req.Assignment,
+
+		trustedProxies:   req.TrustedProxies,
+		schedule:         req.Schedule,
+		bandit:           req.Bandit,
+		strategy:         req.Strategy,
+		layer:            req.Layer,
+		mirror:           req.Mirror,
+		guardrails:       req.Guardrails,
+		outlierDetection: req.OutlierDetection,
+		retry:            req.Retry,
+		transport:        req.Transport,
+		headers:          req.Headers,
+		cookie:           req.Cookie,
+		consent:          req.Consent,
+	}
+
+	if err := s.executeTransaction(c, proxyID, currentProxy, update); err != nil {
 		return // Error already sent to client
 	}
 
-	if err := s.updateSupervisor(c, proxyID, currentProxy, targets, condition); err != nil {
+	if err := s.updateSupervisor(c, proxyID, currentProxy, update); err != nil {
 		return // Error already sent to client
 	}
 
@@ -61,6 +154,103 @@ func (s *Server) parseAndValidateRequest(c *gin.Context) (UpdateTargetsRequest,
 		return req, err
 	}
 
+	if err := validateAssignment(req.Assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidatePrefixes(req.TrustedProxies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateSchedule(req.Schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := validateBandit(req.Bandit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if req.Strategy != nil {
+		if err := validateStrategy(*req.Strategy, req.Condition != nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, err
+		}
+	}
+
+	if req.Layer != nil && req.Layer.LayerID != "" {
+		if err := req.Layer.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, err
+		}
+	}
+
+	if err := proxy.ValidateMirror(req.Mirror); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateGuardrails(req.Guardrails); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateOutlierDetection(req.OutlierDetection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateRetry(req.Retry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateTransport(req.Transport); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateHeaderRules(req.Headers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateCookiePolicy(req.Cookie); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	if err := proxy.ValidateConsent(req.Consent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
+	controls := 0
+	for _, t := range req.Targets {
+		if t.IsControl {
+			controls++
+		}
+		if err := proxy.ValidateHealthCheck(t.HealthCheck); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, err
+		}
+		if err := proxy.ValidateHeaderRules(t.Headers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, err
+		}
+		if err := proxy.ValidatePathRewrite(t.PathRewrite); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, err
+		}
+	}
+	if err := validateControlTargets(controls); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, err
+	}
+
 	return req, nil
 }
 
@@ -74,6 +264,11 @@ func (s *Server) validateCondition(c *gin.Context, req *UpdateTargetsRequest) er
 		return err
 	}
 
+	if err := validateRouteRules(req.Condition.Rules, len(req.Targets)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return err
+	}
+
 	//if err := s.validateConditionTargets(req); err != nil {
 	//	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 	//	return err
@@ -83,15 +278,88 @@ func (s *Server) validateCondition(c *gin.Context, req *UpdateTargetsRequest) er
 }
 
 func (s *Server) validateConditionFields(condition *RouteCondition) error {
+	// A condition may consist of rule trees only
+	if condition.Type == "" && len(condition.Rules) > 0 {
+		return nil
+	}
 	if !models.ConditionType(condition.Type).IsValid() {
 		return errors.New("invalid condition type")
 	}
-	if condition.ParamName == "" {
-		return errors.New("param_name is required for query_param condition")
+	if models.ConditionType(condition.Type).RequiresParam() && condition.ParamName == "" {
+		return errors.New("param_name is required for " + condition.Type + " condition")
 	}
 	if len(condition.Values) == 0 {
 		return errors.New("values map is required for query_param condition")
 	}
+	return validateConditionOperator(condition)
+}
+
+func validateAssignment(assignment *models.Assignment) error {
+	if assignment == nil {
+		return nil
+	}
+	if !assignment.Mode.IsValid() {
+		return errors.New("invalid assignment mode")
+	}
+	switch assignment.Source {
+	case "", models.IdentitySourceRUID:
+	case models.IdentitySourceHeader, models.IdentitySourceCookie, models.IdentitySourceQuery:
+		if assignment.ParamName == "" {
+			return errors.New("param_name is required for assignment source " + string(assignment.Source))
+		}
+	default:
+		return errors.New("invalid assignment source")
+	}
+	return nil
+}
+
+func validateBandit(bandit *models.BanditSettings) error {
+	if bandit == nil {
+		return nil
+	}
+	if bandit.MinExploration < 0 || bandit.MinExploration >= 1 {
+		return errors.New("min_exploration must be in [0, 1)")
+	}
+	return nil
+}
+
+func validateStrategy(strategy string, hasCondition bool) error {
+	if err := proxy.ValidateStrategy(strategy); err != nil {
+		return err
+	}
+	if strategy == proxy.StrategyCondition && !hasCondition {
+		return errors.New("condition strategy requires a condition")
+	}
+	return nil
+}
+
+// validateStrategyMode rejects strategies that cannot work in the proxy mode:
+// latency is only measured for reverse proxied requests
+func validateStrategyMode(strategy string, mode models.ProxyMode) error {
+	if strategy == proxy.StrategyLeastLatency && mode == models.ProxyModeRedirect {
+		return errors.New("least_latency strategy requires reverse proxy mode")
+	}
+	return nil
+}
+
+// validateFallbackTarget checks that the fallback target of outlier detection is
+// one of the proxy's targets, an unknown ID would silently mean "assign anew"
+func validateFallbackTarget(o *models.OutlierDetection, targets []models.Target) error {
+	if o == nil || !o.Enabled || o.FallbackTargetID == "" {
+		return nil
+	}
+	for _, target := range targets {
+		if target.ID == o.FallbackTargetID {
+			return nil
+		}
+	}
+	return fmt.Errorf("fallback_target_id %s is not a target of the proxy", o.FallbackTargetID)
+}
+
+func validateControlTargets(controls int) error {
+	if controls > 1 {
+		return errors.New("only one target can be the control target")
+	}
 	return nil
 }
 
@@ -125,22 +393,50 @@ func (s *Server) getCurrentProxy(c *gin.Context, proxyID string) (*models.Proxy,
 	return p, nil
 }
 
-func (s *Server) convertToTargetModels(proxyID string, req UpdateTargetsRequest) []models.Target {
+// convertToTargetModels builds the new targets, keeping the IDs of existing targets
+// so that stickiness, overrides and counters survive the update
+func (s *Server) convertToTargetModels(proxyID string, req UpdateTargetsRequest, currentProxy *models.Proxy) []models.Target {
+	existing := make(map[string]bool, len(currentProxy.Targets))
+	byURL := make(map[string]string, len(currentProxy.Targets))
+	for _, t := range currentProxy.Targets {
+		existing[t.ID] = true
+		if _, ok := byURL[t.URL]; !ok {
+			byURL[t.URL] = t.ID
+		}
+	}
+
+	used := make(map[string]bool, len(req.Targets))
+	targetID := func(id, url string) string {
+		if id == "" || !existing[id] {
+			id = byURL[url]
+		}
+		if id == "" || used[id] {
+			id = uuid.New().String()
+		}
+		used[id] = true
+		return id
+	}
+
 	targets := make([]models.Target, len(req.Targets))
 	for i, t := range req.Targets {
 		targets[i] = models.Target{
-			ID:       uuid.New().String(),
+			ID:       targetID(t.ID, t.URL),
 			ProxyID:  proxyID,
 			URL:      t.URL,
 			Weight:   t.Weight,
 			IsActive: t.IsActive,
+
+			IsControl:   t.IsControl,
+			HealthCheck: t.HealthCheck,
+			Headers:     t.Headers,
+			PathRewrite: t.PathRewrite,
 		}
 	}
 	return targets
 }
 
 func (s *Server) convertToConditionModels(targets []models.Target, req UpdateTargetsRequest) *models.RouteCondition {
-	if req.Condition == nil || req.Condition.Type == "" {
+	if req.Condition == nil || (req.Condition.Type == "" && len(req.Condition.Rules) == 0) {
 		return nil
 	}
 
@@ -151,8 +447,10 @@ func (s *Server) convertToConditionModels(targets []models.Target, req UpdateTar
 	return &models.RouteCondition{
 		Type:      models.ConditionType(req.Condition.Type),
 		ParamName: req.Condition.ParamName,
+		Operator:  models.MatchOperator(req.Condition.Operator),
 		Values:    conditionValues,
 		Default:   req.Condition.Default,
+		Rules:     convertRouteRules(req.Condition.Rules, targets),
 	}
 }
 
@@ -167,7 +465,7 @@ func (s *Server) getUserID(c *gin.Context) *string {
 
 // Transaction handling
 func (s *Server) executeTransaction(c *gin.Context, proxyID string, currentProxy *models.Proxy,
-	targets []models.Target, condition *models.RouteCondition) error {
+	update proxyUpdate) error {
 
 	tx, err := s.storage.BeginTx(c.Request.Context())
 	if err != nil {
@@ -179,11 +477,11 @@ func (s *Server) executeTransaction(c *gin.Context, proxyID string, currentProxy
 
 	userID := s.getUserID(c)
 
-	if err := s.recordChanges(c, tx, proxyID, currentProxy, targets, condition, userID); err != nil {
+	if err := s.recordChanges(c, tx, proxyID, currentProxy, update, userID); err != nil {
 		return err
 	}
 
-	if err := s.updateStorage(c, tx, proxyID, targets, condition); err != nil {
+	if err := s.updateStorage(c, tx, proxyID, update); err != nil {
 		return err
 	}
 
@@ -197,8 +495,7 @@ func (s *Server) executeTransaction(c *gin.Context, proxyID string, currentProxy
 }
 
 func (s *Server) recordChanges(c *gin.Context, tx *storage.Tx, proxyID string,
-	currentProxy *models.Proxy, targets []models.Target,
-	condition *models.RouteCondition, userID *string) error {
+	currentProxy *models.Proxy, update proxyUpdate, userID *string) error {
 
 	if err := s.storage.RecordProxyChange(
 		c.Request.Context(),
@@ -206,7 +503,7 @@ func (s *Server) recordChanges(c *gin.Context, tx *storage.Tx, proxyID string,
 		proxyID,
 		models.ChangeTypeTargetsUpdate,
 		currentProxy.Targets,
-		targets,
+		update.targets,
 		userID,
 	); err != nil {
 		c.JSON(http.StatusInternalServerError,
@@ -214,14 +511,14 @@ func (s *Server) recordChanges(c *gin.Context, tx *storage.Tx, proxyID string,
 		return err
 	}
 
-	if condition != nil {
+	if update.condition != nil {
 		if err := s.storage.RecordProxyChange(
 			c.Request.Context(),
 			tx,
 			proxyID,
 			models.ChangeTypeConditionUpdate,
 			currentProxy.Condition,
-			condition,
+			update.condition,
 			userID,
 		); err != nil {
 			c.JSON(http.StatusInternalServerError,
@@ -230,34 +527,368 @@ func (s *Server) recordChanges(c *gin.Context, tx *storage.Tx, proxyID string,
 		}
 	}
 
+	if update.assignment != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeAssignmentUpdate,
+			currentProxy.Assignment,
+			update.assignment,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record assignment changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.trustedProxies != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeTrustedProxies,
+			currentProxy.TrustedProxies,
+			update.trustedProxies,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record trusted proxies changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.schedule != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeScheduleUpdate,
+			currentProxy.Schedule,
+			update.schedule,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record schedule changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.bandit != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeBanditUpdate,
+			currentProxy.Bandit,
+			update.bandit,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record bandit changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.strategy != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeStrategyUpdate,
+			currentProxy.Strategy,
+			*update.strategy,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record strategy changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.layer != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeLayerUpdate,
+			currentProxy.Layer,
+			update.layer,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record layer changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.mirror != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeMirrorUpdate,
+			currentProxy.Mirror,
+			update.mirror,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record mirror changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.guardrails != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeGuardrailsUpdate,
+			currentProxy.Guardrails,
+			update.guardrails,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record guardrails changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.outlierDetection != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeOutlierDetectionUpdate,
+			currentProxy.OutlierDetection,
+			update.outlierDetection,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record outlier detection changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.retry != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeRetryUpdate,
+			currentProxy.Retry,
+			update.retry,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record retry policy changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.transport != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeTransportUpdate,
+			currentProxy.Transport,
+			update.transport,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record transport settings changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.headers != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeHeadersUpdate,
+			currentProxy.Headers,
+			update.headers,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record header rules changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.cookie != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeCookieUpdate,
+			currentProxy.Cookie,
+			update.cookie,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record cookie policy changes: %v", err)})
+			return err
+		}
+	}
+
+	if update.consent != nil {
+		if err := s.storage.RecordProxyChange(
+			c.Request.Context(),
+			tx,
+			proxyID,
+			models.ChangeTypeConsentUpdate,
+			currentProxy.Consent,
+			update.consent,
+			userID,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to record consent policy changes: %v", err)})
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (s *Server) updateStorage(c *gin.Context, tx *storage.Tx, proxyID string,
-	targets []models.Target, condition *models.RouteCondition) error {
-
-	if err := s.storage.UpdateTargetsWithTx(c.Request.Context(), tx, proxyID, targets); err != nil {
+func (s *Server) updateStorage(c *gin.Context, tx *storage.Tx, proxyID string, update proxyUpdate) error {
+	if err := s.storage.UpdateTargetsWithTx(c.Request.Context(), tx, proxyID, update.targets); err != nil {
 		c.JSON(http.StatusInternalServerError,
 			gin.H{"error": fmt.Sprintf("failed to update targets: %v", err)})
 		return err
 	}
 
-	if condition != nil {
-		if err := s.storage.UpdateProxyConditionWithTx(c.Request.Context(), tx, proxyID, condition); err != nil {
+	if update.condition != nil {
+		if err := s.storage.UpdateProxyConditionWithTx(c.Request.Context(), tx, proxyID, update.condition); err != nil {
 			c.JSON(http.StatusInternalServerError,
 				gin.H{"error": fmt.Sprintf("failed to update condition: %v", err)})
 			return err
 		}
 	}
 
+	if update.assignment != nil {
+		if err := s.storage.UpdateProxyAssignmentWithTx(c.Request.Context(), tx, proxyID, update.assignment); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update assignment: %v", err)})
+			return err
+		}
+	}
+
+	if update.trustedProxies != nil {
+		if err := s.storage.UpdateProxyTrustedProxiesWithTx(c.Request.Context(), tx, proxyID, update.trustedProxies); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update trusted proxies: %v", err)})
+			return err
+		}
+	}
+
+	if update.schedule != nil {
+		if err := s.storage.UpdateProxyScheduleWithTx(c.Request.Context(), tx, proxyID, update.schedule); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update schedule: %v", err)})
+			return err
+		}
+	}
+
+	if update.bandit != nil {
+		if err := s.storage.UpdateProxyBanditWithTx(c.Request.Context(), tx, proxyID, update.bandit); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update bandit: %v", err)})
+			return err
+		}
+	}
+
+	if update.strategy != nil {
+		if err := s.storage.UpdateProxyStrategyWithTx(c.Request.Context(), tx, proxyID, *update.strategy); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update strategy: %v", err)})
+			return err
+		}
+	}
+
+	if update.layer != nil {
+		if err := s.storage.UpdateProxyLayerWithTx(c.Request.Context(), tx, proxyID, update.layer); err != nil {
+			c.JSON(layerErrorStatus(err),
+				gin.H{"error": fmt.Sprintf("failed to update layer: %v", err)})
+			return err
+		}
+	}
+
+	if update.mirror != nil {
+		if err := s.storage.UpdateProxyMirrorWithTx(c.Request.Context(), tx, proxyID, update.mirror); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update mirror: %v", err)})
+			return err
+		}
+	}
+
+	if update.guardrails != nil {
+		if err := s.storage.UpdateProxyGuardrailsWithTx(c.Request.Context(), tx, proxyID, update.guardrails); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update guardrails: %v", err)})
+			return err
+		}
+	}
+
+	if update.outlierDetection != nil {
+		if err := s.storage.UpdateProxyOutlierDetectionWithTx(c.Request.Context(), tx, proxyID, update.outlierDetection); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update outlier detection: %v", err)})
+			return err
+		}
+	}
+
+	if update.retry != nil {
+		if err := s.storage.UpdateProxyRetryWithTx(c.Request.Context(), tx, proxyID, update.retry); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update retry policy: %v", err)})
+			return err
+		}
+	}
+
+	if update.transport != nil {
+		if err := s.storage.UpdateProxyTransportWithTx(c.Request.Context(), tx, proxyID, update.transport); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update transport settings: %v", err)})
+			return err
+		}
+	}
+
+	if update.headers != nil {
+		if err := s.storage.UpdateProxyHeadersWithTx(c.Request.Context(), tx, proxyID, update.headers); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update header rules: %v", err)})
+			return err
+		}
+	}
+
+	if update.cookie != nil {
+		if err := s.storage.UpdateProxyCookieWithTx(c.Request.Context(), tx, proxyID, update.cookie); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update cookie policy: %v", err)})
+			return err
+		}
+	}
+
+	if update.consent != nil {
+		if err := s.storage.UpdateProxyConsentWithTx(c.Request.Context(), tx, proxyID, update.consent); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				gin.H{"error": fmt.Sprintf("failed to update consent policy: %v", err)})
+			return err
+		}
+	}
+
 	return nil
 }
 
 // Supervisor update
 func (s *Server) updateSupervisor(c *gin.Context, proxyID string, currentProxy *models.Proxy,
-	targets []models.Target, condition *models.RouteCondition) error {
+	update proxyUpdate) error {
 
-	config := s.buildProxyConfig(proxyID, currentProxy, targets, condition)
+	config := s.buildProxyConfig(proxyID, currentProxy, update)
 
 	if err := s.supervisor.UpdateProxyTargets(c.Request.Context(), config); err != nil {
 		c.JSON(http.StatusInternalServerError,
@@ -269,24 +900,100 @@ func (s *Server) updateSupervisor(c *gin.Context, proxyID string, currentProxy *
 }
 
 func (s *Server) buildProxyConfig(proxyID string, currentProxy *models.Proxy,
-	targets []models.Target, condition *models.RouteCondition) proxy.Config {
+	update proxyUpdate) proxy.Config {
 
 	config := proxy.Config{
-		ID:        proxyID,
-		ListenURL: currentProxy.ListenURL,
-		Mode:      models.ProxyMode(currentProxy.Mode),
-		Targets:   s.convertToConfigTargets(targets),
-	}
-
-	if condition != nil {
+		ID:         proxyID,
+		ListenURL:  currentProxy.ListenURL,
+		Mode:       models.ProxyMode(currentProxy.Mode),
+		Targets:    s.convertToConfigTargets(update.targets),
+		Assignment: currentProxy.Assignment,
+
+		TrustedProxies:   currentProxy.TrustedProxies,
+		Schedule:         currentProxy.Schedule,
+		Bandit:           currentProxy.Bandit,
+		Strategy:         currentProxy.Strategy,
+		Layer:            currentProxy.Layer,
+		Mirror:           currentProxy.Mirror,
+		Guardrails:       currentProxy.Guardrails,
+		OutlierDetection: currentProxy.OutlierDetection,
+		Retry:            currentProxy.Retry,
+		Transport:        currentProxy.Transport,
+		Headers:          currentProxy.Headers,
+		Cookie:           currentProxy.Cookie,
+		Consent:          currentProxy.Consent,
+	}
+
+	if condition := update.condition; condition != nil {
 		config.Condition = &proxy.Condition{
 			Type:      condition.Type,
 			ParamName: condition.ParamName,
+			Operator:  condition.Operator,
 			Values:    condition.Values,
 			Default:   condition.Default,
+			Rules:     condition.Rules,
+		}
+	}
+
+	if update.assignment != nil {
+		config.Assignment = update.assignment
+	}
+
+	if update.trustedProxies != nil {
+		config.TrustedProxies = update.trustedProxies
+	}
+
+	if update.schedule != nil {
+		config.Schedule = update.schedule
+	}
+
+	if update.bandit != nil {
+		config.Bandit = update.bandit
+	}
+
+	if update.strategy != nil {
+		config.Strategy = *update.strategy
+	}
+
+	if update.layer != nil {
+		config.Layer = update.layer
+		if update.layer.LayerID == "" {
+			config.Layer = nil
 		}
 	}
 
+	if update.mirror != nil {
+		config.Mirror = update.mirror
+	}
+
+	if update.guardrails != nil {
+		config.Guardrails = update.guardrails
+	}
+
+	if update.outlierDetection != nil {
+		config.OutlierDetection = update.outlierDetection
+	}
+
+	if update.retry != nil {
+		config.Retry = update.retry
+	}
+
+	if update.transport != nil {
+		config.Transport = update.transport
+	}
+
+	if update.headers != nil {
+		config.Headers = update.headers
+	}
+
+	if update.cookie != nil {
+		config.Cookie = update.cookie
+	}
+
+	if update.consent != nil {
+		config.Consent = update.consent
+	}
+
 	return config
 }
 
@@ -298,6 +1005,11 @@ func (s *Server) convertToConfigTargets(targets []models.Target) []proxy.Target
 			URL:      t.URL,
 			Weight:   t.Weight,
 			IsActive: t.IsActive,
+
+			IsControl:   t.IsControl,
+			HealthCheck: t.HealthCheck,
+			Headers:     t.Headers,
+			PathRewrite: t.PathRewrite,
 		}
 	}
 	return configTargets
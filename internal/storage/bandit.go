@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// Bandit counters are shared by all service instances, one hash per proxy
+// with "<target_id>:exposures" and "<target_id>:conversions" fields
+func banditKey(proxyID string) string {
+	return fmt.Sprintf("bandit:%s", proxyID)
+}
+
+func (s *Storage) AddBanditExposures(ctx context.Context, proxyID string, exposures map[string]int64) error {
+	pipe := s.Redis.TxPipeline()
+	for targetID, count := range exposures {
+		if count > 0 {
+			pipe.HIncrBy(ctx, banditKey(proxyID), targetID+":exposures", count)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *Storage) AddBanditConversions(ctx context.Context, proxyID, targetID string, count int64) error {
+	return s.Redis.HIncrBy(ctx, banditKey(proxyID), targetID+":conversions", count).Err()
+}
+
+func (s *Storage) GetBanditCounters(ctx context.Context, proxyID string) (map[string]models.BanditCounters, error) {
+	fields, err := s.Redis.HGetAll(ctx, banditKey(proxyID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bandit counters: %w", err)
+	}
+
+	counters := make(map[string]models.BanditCounters)
+	for field, value := range fields {
+		targetID, kind, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		c := counters[targetID]
+		switch kind {
+		case "exposures":
+			c.Exposures = n
+		case "conversions":
+			c.Conversions = n
+		}
+		counters[targetID] = c
+	}
+	return counters, nil
+}
+
+// AcquireLock takes a lock shared by all service instances that expires after ttl,
+// so periodic jobs run on a single instance
+func (s *Storage) AcquireLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	return s.Redis.SetNX(ctx, "lock:"+name, "1", ttl).Result()
+}
@@ -69,27 +69,16 @@ func (s *Storage) GetProxy(ctx context.Context, id string) (*models.Proxy, error
 	}
 
 	// Fallback to PostgreSQL
-	var proxy models.Proxy
-	var conditionJSON []byte
-
-	err = s.db.QueryRowContext(ctx,
-		`SELECT id, listen_url, mode, condition, created_at, updated_at
-		FROM proxies WHERE id = $1`,
+	proxy, err := scanProxy(s.db.QueryRowContext(ctx,
+		`SELECT `+proxyColumns+` FROM proxies WHERE id = $1`,
 		id,
-	).Scan(&proxy.ID, &proxy.ListenURL, &proxy.Mode, &conditionJSON, &proxy.CreatedAt, &proxy.UpdatedAt)
+	))
 	if err != nil {
 		return nil, err
 	}
 
-	if len(conditionJSON) > 0 {
-		proxy.Condition = &models.RouteCondition{}
-		if err := json.Unmarshal(conditionJSON, proxy.Condition); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal condition: %w", err)
-		}
-	}
-
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, url, weight, is_active FROM targets WHERE proxy_id = $1`,
+		`SELECT `+targetColumns+` FROM targets WHERE proxy_id = $1`,
 		id,
 	)
 	if err != nil {
@@ -98,9 +87,8 @@ func (s *Storage) GetProxy(ctx context.Context, id string) (*models.Proxy, error
 	defer rows.Close()
 
 	for rows.Next() {
-		var target models.Target
-		target.ProxyID = proxy.ID
-		if err := rows.Scan(&target.ID, &target.URL, &target.Weight, &target.IsActive); err != nil {
+		target, err := scanTarget(rows)
+		if err != nil {
 			return nil, err
 		}
 		proxy.Targets = append(proxy.Targets, target)
@@ -111,7 +99,7 @@ func (s *Storage) GetProxy(ctx context.Context, id string) (*models.Proxy, error
 		s.Redis.Set(ctx, key, data, proxyTTL)
 	}
 
-	return &proxy, nil
+	return proxy, nil
 }
 
 func (s *Storage) GetTargets(ctx context.Context, proxyID string) ([]*models.Target, error) {
@@ -127,7 +115,7 @@ func (s *Storage) GetTargets(ctx context.Context, proxyID string) ([]*models.Tar
 
 	// Fallback to PostgreSQL
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, proxy_id, url, weight, is_active FROM targets WHERE proxy_id = $1`,
+		`SELECT `+targetColumns+` FROM targets WHERE proxy_id = $1`,
 		proxyID,
 	)
 	if err != nil {
@@ -137,8 +125,8 @@ func (s *Storage) GetTargets(ctx context.Context, proxyID string) ([]*models.Tar
 
 	var targets []*models.Target
 	for rows.Next() {
-		var target models.Target
-		if err := rows.Scan(&target.ID, &target.ProxyID, &target.URL, &target.Weight, &target.IsActive); err != nil {
+		target, err := scanTarget(rows)
+		if err != nil {
 			return nil, err
 		}
 		targets = append(targets, &target)
@@ -33,26 +33,89 @@ func (s *Storage) CreateProxy(ctx context.Context, proxy *models.Proxy) error {
 		conditionJSON = bytes // Если есть данные, присваиваем []byte
 	}
 
+	assignmentJSON, err := nullableJSON(proxy.Assignment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal assignment: %w", err)
+	}
+
+	scheduleJSON, err := nullableJSON(proxy.Schedule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule: %w", err)
+	}
+
+	banditJSON, err := nullableJSON(proxy.Bandit)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bandit: %w", err)
+	}
+
+	mirrorJSON, err := nullableJSON(proxy.Mirror)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror: %w", err)
+	}
+
+	guardrailsJSON, err := nullableJSON(proxy.Guardrails)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guardrails: %w", err)
+	}
+
+	outlierDetectionJSON, err := nullableJSON(proxy.OutlierDetection)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outlier detection: %w", err)
+	}
+
+	retryJSON, err := nullableJSON(proxy.Retry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retry policy: %w", err)
+	}
+
+	transportJSON, err := nullableJSON(proxy.Transport)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transport settings: %w", err)
+	}
+
+	headersJSON, err := nullableJSON(proxy.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal header rules: %w", err)
+	}
+
+	cookieJSON, err := nullableJSON(proxy.Cookie)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cookie policy: %w", err)
+	}
+
+	consentJSON, err := nullableJSON(proxy.Consent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consent policy: %w", err)
+	}
+
+	overrideSecret, err := newOverrideSecret()
+	if err != nil {
+		return fmt.Errorf("failed to generate override secret: %w", err)
+	}
+
 	_, err = tx.ExecContext(ctx,
-		`INSERT INTO proxies (id, listen_url, mode, condition, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		proxy.ID, proxy.ListenURL, proxy.Mode, conditionJSON, pq.Array(proxy.Tags), proxy.CreatedAt, proxy.UpdatedAt,
+		`INSERT INTO proxies (id, listen_url, mode, condition, assignment, trusted_proxies, schedule, bandit, strategy, override_secret, mirror, guardrails, outlier_detection, retry_policy, transport, headers, cookie_policy, consent_policy, tags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`,
+		proxy.ID, proxy.ListenURL, proxy.Mode, conditionJSON, assignmentJSON, pq.Array(proxy.TrustedProxies),
+		scheduleJSON, banditJSON, proxy.Strategy, overrideSecret, mirrorJSON, guardrailsJSON, outlierDetectionJSON, retryJSON, transportJSON, headersJSON, cookieJSON, consentJSON, pq.Array(proxy.Tags), proxy.CreatedAt, proxy.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert proxy: %w, condition: %s, condition type: %T, condition value: %+v", err, conditionJSON, proxy.Condition, proxy.Condition)
 	}
 
+	if proxy.Layer != nil {
+		if err := setProxyLayer(ctx, tx, proxy.ID, proxy.Layer); err != nil {
+			return err
+		}
+		proxy.Layer.ProxyID = proxy.ID
+	}
+
 	// Insert targets
 	for i := range proxy.Targets {
 		target := &proxy.Targets[i]
 		target.ProxyID = proxy.ID
 
-		_, err = tx.ExecContext(ctx,
-			`INSERT INTO targets (id, proxy_id, url, weight, is_active)
-			VALUES ($1, $2, $3, $4, $5)`,
-			target.ID, target.ProxyID, target.URL, target.Weight, target.IsActive,
-		)
-		if err != nil {
+		if err := insertTarget(ctx, tx, proxy.ID, *target); err != nil {
 			return fmt.Errorf("failed to insert target: %w", err)
 		}
 	}
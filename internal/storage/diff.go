@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+// maxDiffExamples is the number of recent mismatch examples kept per proxy
+const maxDiffExamples = 100
+
+// Diff counters are kept in a hash per proxy with "compared:<shadow>" and
+// "mismatched:<shadow>" fields, examples in a capped list
+func diffCountersKey(proxyID string) string {
+	return fmt.Sprintf("diffs:%s", proxyID)
+}
+
+func diffExamplesKey(proxyID string) string {
+	return fmt.Sprintf("diff_examples:%s", proxyID)
+}
+
+func (s *Storage) AddDiffs(ctx context.Context, proxyID string, summaries []models.DiffSummary, examples []models.ResponseDiff) error {
+	pipe := s.Redis.TxPipeline()
+	for _, summary := range summaries {
+		pipe.HIncrBy(ctx, diffCountersKey(proxyID), "compared:"+summary.Shadow, summary.Compared)
+		pipe.HIncrBy(ctx, diffCountersKey(proxyID), "mismatched:"+summary.Shadow, summary.Mismatched)
+	}
+	for _, example := range examples {
+		data, err := json.Marshal(example)
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff example: %w", err)
+		}
+		pipe.LPush(ctx, diffExamplesKey(proxyID), data)
+	}
+	if len(examples) > 0 {
+		pipe.LTrim(ctx, diffExamplesKey(proxyID), 0, maxDiffExamples-1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetDiffs returns the mismatch counts per shadow target and the most recent examples
+func (s *Storage) GetDiffs(ctx context.Context, proxyID string, limit int) ([]models.DiffSummary, []models.ResponseDiff, error) {
+	fields, err := s.Redis.HGetAll(ctx, diffCountersKey(proxyID)).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get diff counters: %w", err)
+	}
+
+	byShadow := make(map[string]*models.DiffSummary)
+	for field, value := range fields {
+		kind, shadow, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		summary, exists := byShadow[shadow]
+		if !exists {
+			summary = &models.DiffSummary{Shadow: shadow}
+			byShadow[shadow] = summary
+		}
+		switch kind {
+		case "compared":
+			summary.Compared = n
+		case "mismatched":
+			summary.Mismatched = n
+		}
+	}
+
+	summaries := make([]models.DiffSummary, 0, len(byShadow))
+	for _, summary := range byShadow {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Shadow < summaries[j].Shadow })
+
+	values, err := s.Redis.LRange(ctx, diffExamplesKey(proxyID), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get diff examples: %w", err)
+	}
+	examples := make([]models.ResponseDiff, 0, len(values))
+	for _, value := range values {
+		var example models.ResponseDiff
+		if err := json.Unmarshal([]byte(value), &example); err == nil {
+			examples = append(examples, example)
+		}
+	}
+
+	return summaries, examples, nil
+}
+
+func (s *Storage) ResetDiffs(ctx context.Context, proxyID string) error {
+	return s.Redis.Del(ctx, diffCountersKey(proxyID), diffExamplesKey(proxyID)).Err()
+}
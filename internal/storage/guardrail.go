@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+)
+
+// Guardrail samples are kept in a hash per proxy and window with "<target_id>:requests",
+// "<target_id>:errors" and "<target_id>:latency:<bucket>" fields
+func guardrailKey(proxyID string, windowStart time.Time) string {
+	return fmt.Sprintf("guardrails:%s:%d", proxyID, windowStart.Unix())
+}
+
+// AddGuardrailSamples adds samples to the window that contains the current time
+func (s *Storage) AddGuardrailSamples(ctx context.Context, proxyID string, window time.Duration, samples map[string]models.GuardrailSample) error {
+	key := guardrailKey(proxyID, time.Now().Truncate(window))
+
+	pipe := s.Redis.TxPipeline()
+	for targetID, sample := range samples {
+		if sample.Requests > 0 {
+			pipe.HIncrBy(ctx, key, targetID+":requests", sample.Requests)
+		}
+		if sample.Errors > 0 {
+			pipe.HIncrBy(ctx, key, targetID+":errors", sample.Errors)
+		}
+		for bucket, count := range sample.Latency {
+			if count > 0 {
+				pipe.HIncrBy(ctx, key, fmt.Sprintf("%s:latency:%d", targetID, bucket), count)
+			}
+		}
+	}
+	// The window is still read while the next one fills up
+	pipe.Expire(ctx, key, 2*window)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetGuardrailSamples returns the samples of the current and the previous window,
+// so a rule is never evaluated on a window that has just started
+func (s *Storage) GetGuardrailSamples(ctx context.Context, proxyID string, window time.Duration) (map[string]models.GuardrailSample, error) {
+	current := time.Now().Truncate(window)
+
+	samples := make(map[string]models.GuardrailSample)
+	for _, start := range []time.Time{current.Add(-window), current} {
+		fields, err := s.Redis.HGetAll(ctx, guardrailKey(proxyID, start)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get guardrail samples: %w", err)
+		}
+
+		for field, value := range fields {
+			targetID, kind, ok := strings.Cut(field, ":")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			sample := samples[targetID]
+			switch kind {
+			case "requests":
+				sample.Requests += n
+			case "errors":
+				sample.Errors += n
+			default:
+				bucket, err := strconv.Atoi(strings.TrimPrefix(kind, "latency:"))
+				if err != nil || bucket < 0 || bucket > len(proxy.LatencyBuckets) {
+					continue
+				}
+				if sample.Latency == nil {
+					sample.Latency = make([]int64, len(proxy.LatencyBuckets)+1)
+				}
+				sample.Latency[bucket] += n
+			}
+			samples[targetID] = sample
+		}
+	}
+	return samples, nil
+}
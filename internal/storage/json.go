@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"encoding/json"
+)
+
+// nullableJSON marshals v for a nullable JSONB column, returning nil for nil values
+func nullableJSON[T any](v *T) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalNullable decodes a nullable JSONB column, returning nil for NULL values
+func unmarshalNullable[T any](data []byte) (*T, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	v := new(T)
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
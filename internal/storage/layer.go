@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+var (
+	ErrLayerNotFound   = errors.New("layer not found")
+	ErrLayerInUse      = errors.New("layer has proxies attached")
+	ErrLayerRangeTaken = errors.New("bucket range overlaps another proxy of the layer")
+)
+
+func (s *Storage) CreateLayer(ctx context.Context, layer *models.Layer) error {
+	layer.ID = uuid.New().String()
+	layer.CreatedAt = time.Now()
+	layer.UpdatedAt = layer.CreatedAt
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO layers (id, name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		layer.ID, layer.Name, layer.Description, layer.CreatedAt, layer.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert layer: %w", err)
+	}
+	layer.Allocations = []models.LayerAllocation{}
+	return nil
+}
+
+func (s *Storage) GetLayers(ctx context.Context) ([]models.Layer, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, description, created_at, updated_at FROM layers ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query layers: %w", err)
+	}
+	defer rows.Close()
+
+	layers := []models.Layer{}
+	for rows.Next() {
+		var layer models.Layer
+		if err := rows.Scan(&layer.ID, &layer.Name, &layer.Description, &layer.CreatedAt, &layer.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan layer: %w", err)
+		}
+		layers = append(layers, layer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	allocations, err := s.getLayerAllocations(ctx, s.db, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range layers {
+		layers[i].Allocations = allocations[layers[i].ID]
+		if layers[i].Allocations == nil {
+			layers[i].Allocations = []models.LayerAllocation{}
+		}
+	}
+	return layers, nil
+}
+
+func (s *Storage) GetLayer(ctx context.Context, id string) (*models.Layer, error) {
+	var layer models.Layer
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, name, description, created_at, updated_at FROM layers WHERE id = $1`,
+		id,
+	).Scan(&layer.ID, &layer.Name, &layer.Description, &layer.CreatedAt, &layer.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLayerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer: %w", err)
+	}
+
+	allocations, err := s.getLayerAllocations(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	layer.Allocations = allocations[id]
+	if layer.Allocations == nil {
+		layer.Allocations = []models.LayerAllocation{}
+	}
+	return &layer, nil
+}
+
+func (s *Storage) UpdateLayer(ctx context.Context, layer *models.Layer) error {
+	layer.UpdatedAt = time.Now()
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE layers SET name = $1, description = $2, updated_at = $3 WHERE id = $4`,
+		layer.Name, layer.Description, layer.UpdatedAt, layer.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update layer: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrLayerNotFound
+	}
+	return nil
+}
+
+// DeleteLayer removes a layer that no proxy is attached to
+func (s *Storage) DeleteLayer(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockLayer(ctx, tx, id); err != nil {
+		return err
+	}
+
+	var attached int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM proxies WHERE layer_id = $1`,
+		id,
+	).Scan(&attached); err != nil {
+		return fmt.Errorf("failed to count layer proxies: %w", err)
+	}
+	if attached > 0 {
+		return ErrLayerInUse
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM layers WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete layer: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) UpdateProxyLayerWithTx(ctx context.Context, tx *Tx, proxyID string, layer *models.LayerAllocation) error {
+	return setProxyLayer(ctx, tx.tx, proxyID, layer)
+}
+
+// setProxyLayer attaches the proxy to the bucket range of a layer, or detaches it
+// when the layer ID is empty. The layer row is locked so that concurrent
+// allocations cannot overlap.
+func setProxyLayer(ctx context.Context, tx *sql.Tx, proxyID string, layer *models.LayerAllocation) error {
+	if layer == nil || layer.LayerID == "" {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE proxies SET layer_id = NULL, layer_bucket_start = NULL, layer_bucket_end = NULL, updated_at = $1
+			WHERE id = $2`,
+			time.Now(), proxyID,
+		)
+		return err
+	}
+
+	if err := lockLayer(ctx, tx, layer.LayerID); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, layer_bucket_start, layer_bucket_end FROM proxies WHERE layer_id = $1 AND id <> $2`,
+		layer.LayerID, proxyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query layer proxies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		other := models.LayerAllocation{LayerID: layer.LayerID}
+		if err := rows.Scan(&other.ProxyID, &other.BucketStart, &other.BucketEnd); err != nil {
+			return fmt.Errorf("failed to scan layer allocation: %w", err)
+		}
+		if layer.Overlaps(other) {
+			return fmt.Errorf("%w: proxy %s owns buckets %d-%d",
+				ErrLayerRangeTaken, other.ProxyID, other.BucketStart, other.BucketEnd)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE proxies SET layer_id = $1, layer_bucket_start = $2, layer_bucket_end = $3, updated_at = $4
+		WHERE id = $5`,
+		layer.LayerID, layer.BucketStart, layer.BucketEnd, time.Now(), proxyID,
+	)
+	return err
+}
+
+func lockLayer(ctx context.Context, tx *sql.Tx, id string) error {
+	var locked string
+	err := tx.QueryRowContext(ctx, `SELECT id FROM layers WHERE id = $1 FOR UPDATE`, id).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrLayerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock layer: %w", err)
+	}
+	return nil
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getLayerAllocations returns the bucket ranges of all proxies by layer ID,
+// limited to one layer if layerID is set
+func (s *Storage) getLayerAllocations(ctx context.Context, db queryer, layerID string) (map[string][]models.LayerAllocation, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, layer_id, layer_bucket_start, layer_bucket_end FROM proxies
+		WHERE layer_id IS NOT NULL AND ($1 = '' OR layer_id = $1)
+		ORDER BY layer_bucket_start`,
+		layerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query layer allocations: %w", err)
+	}
+	defer rows.Close()
+
+	allocations := make(map[string][]models.LayerAllocation)
+	for rows.Next() {
+		var a models.LayerAllocation
+		if err := rows.Scan(&a.ProxyID, &a.LayerID, &a.BucketStart, &a.BucketEnd); err != nil {
+			return nil, fmt.Errorf("failed to scan layer allocation: %w", err)
+		}
+		allocations[a.LayerID] = append(allocations[a.LayerID], a)
+	}
+	return allocations, rows.Err()
+}
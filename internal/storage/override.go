@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+var (
+	ErrOverrideNotFound = errors.New("override not found")
+	ErrOverrideExists   = errors.New("override for this identity already exists")
+)
+
+// newOverrideSecret generates the secret a proxy signs override tokens with
+func newOverrideSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Storage) GetOverrideSecret(ctx context.Context, proxyID string) (string, error) {
+	var secret string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT override_secret FROM proxies WHERE id = $1`,
+		proxyID,
+	).Scan(&secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to get override secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (s *Storage) GetOverrides(ctx context.Context, proxyID string) ([]models.Override, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, proxy_id, identity, target_id, note, created_at, created_by
+		FROM proxy_overrides
+		WHERE proxy_id = $1
+		ORDER BY created_at`,
+		proxyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overrides: %w", err)
+	}
+	defer rows.Close()
+
+	overrides := []models.Override{}
+	for rows.Next() {
+		var o models.Override
+		if err := rows.Scan(&o.ID, &o.ProxyID, &o.Identity, &o.TargetID, &o.Note, &o.CreatedAt, &o.CreatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan override: %w", err)
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+func (s *Storage) CreateOverride(ctx context.Context, o *models.Override) error {
+	o.ID = uuid.New().String()
+	o.CreatedAt = time.Now()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO proxy_overrides (id, proxy_id, identity, target_id, note, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		o.ID, o.ProxyID, o.Identity, o.TargetID, o.Note, o.CreatedAt, o.CreatedBy,
+	)
+	if isUniqueViolation(err) {
+		return ErrOverrideExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert override: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) UpdateOverride(ctx context.Context, o *models.Override) error {
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE proxy_overrides SET identity = $1, target_id = $2, note = $3
+		WHERE id = $4 AND proxy_id = $5
+		RETURNING created_at, created_by`,
+		o.Identity, o.TargetID, o.Note, o.ID, o.ProxyID,
+	).Scan(&o.CreatedAt, &o.CreatedBy)
+	if isUniqueViolation(err) {
+		return ErrOverrideExists
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOverrideNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update override: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) DeleteOverride(ctx context.Context, proxyID, id string) error {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM proxy_overrides WHERE id = $1 AND proxy_id = $2`,
+		id, proxyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete override: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+)
+
+// proxyColumns lists the proxies table columns in the order scanProxy expects
+const proxyColumns = `id, listen_url, mode, condition, assignment, trusted_proxies, schedule, bandit, strategy,
+	layer_id, layer_bucket_start, layer_bucket_end, mirror, guardrails, outlier_detection, retry_policy, transport, headers, cookie_policy, consent_policy, tags, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProxy(row rowScanner) (*models.Proxy, error) {
+	var p models.Proxy
+	var conditionJSON, assignmentJSON, scheduleJSON, banditJSON, mirrorJSON, guardrailsJSON, outlierDetectionJSON, retryJSON, transportJSON, headersJSON, cookieJSON, consentJSON []byte
+	var layerID sql.NullString
+	var bucketStart, bucketEnd sql.NullInt64
+
+	if err := row.Scan(
+		&p.ID,
+		&p.ListenURL,
+		&p.Mode,
+		&conditionJSON,
+		&assignmentJSON,
+		pq.Array(&p.TrustedProxies),
+		&scheduleJSON,
+		&banditJSON,
+		&p.Strategy,
+		&layerID,
+		&bucketStart,
+		&bucketEnd,
+		&mirrorJSON,
+		&guardrailsJSON,
+		&outlierDetectionJSON,
+		&retryJSON,
+		&transportJSON,
+		&headersJSON,
+		&cookieJSON,
+		&consentJSON,
+		pq.Array(&p.Tags),
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if len(conditionJSON) > 0 {
+		p.Condition = &models.RouteCondition{}
+		if err := json.Unmarshal(conditionJSON, p.Condition); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal condition: %w", err)
+		}
+	}
+
+	var err error
+	if p.Assignment, err = unmarshalNullable[models.Assignment](assignmentJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal assignment: %w", err)
+	}
+	if p.Schedule, err = unmarshalNullable[models.Schedule](scheduleJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule: %w", err)
+	}
+	if p.Bandit, err = unmarshalNullable[models.BanditSettings](banditJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bandit: %w", err)
+	}
+	if p.Mirror, err = unmarshalNullable[models.MirrorSettings](mirrorJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mirror: %w", err)
+	}
+	if p.Guardrails, err = unmarshalNullable[models.GuardrailSettings](guardrailsJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal guardrails: %w", err)
+	}
+	if p.OutlierDetection, err = unmarshalNullable[models.OutlierDetection](outlierDetectionJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outlier detection: %w", err)
+	}
+	if p.Retry, err = unmarshalNullable[models.RetryPolicy](retryJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal retry policy: %w", err)
+	}
+	if p.Transport, err = unmarshalNullable[models.TransportSettings](transportJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transport settings: %w", err)
+	}
+	if p.Headers, err = unmarshalNullable[models.HeaderRules](headersJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal header rules: %w", err)
+	}
+	if p.Cookie, err = unmarshalNullable[models.CookiePolicy](cookieJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cookie policy: %w", err)
+	}
+	if p.Consent, err = unmarshalNullable[models.ConsentPolicy](consentJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal consent policy: %w", err)
+	}
+
+	if layerID.Valid {
+		p.Layer = &models.LayerAllocation{
+			LayerID:     layerID.String,
+			ProxyID:     p.ID,
+			BucketStart: int(bucketStart.Int64),
+			BucketEnd:   int(bucketEnd.Int64),
+		}
+	}
+
+	return &p, nil
+}
+
+// proxyConfig converts a stored proxy into the supervisor configuration, without targets
+func proxyConfig(p *models.Proxy) proxy.Config {
+	return proxy.Config{
+		ID:               p.ID,
+		ListenURL:        p.ListenURL,
+		Mode:             models.ProxyMode(p.Mode),
+		Condition:        (*proxy.Condition)(p.Condition),
+		Assignment:       p.Assignment,
+		TrustedProxies:   p.TrustedProxies,
+		Schedule:         p.Schedule,
+		Bandit:           p.Bandit,
+		Strategy:         p.Strategy,
+		Layer:            p.Layer,
+		Mirror:           p.Mirror,
+		Guardrails:       p.Guardrails,
+		OutlierDetection: p.OutlierDetection,
+		Retry:            p.Retry,
+		Transport:        p.Transport,
+		Headers:          p.Headers,
+		Cookie:           p.Cookie,
+		Consent:          p.Consent,
+		Tags:             p.Tags,
+	}
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+var (
+	ErrRolloutNotFound = errors.New("rollout not found")
+	ErrRolloutActive   = errors.New("proxy already has an active rollout")
+	ErrRolloutChanged  = errors.New("rollout was changed concurrently")
+)
+
+const rolloutColumns = `id, proxy_id, target_id, steps, base_weights, current_step, status, step_started_at, paused_at,
+	created_at, updated_at, created_by`
+
+func scanRollout(row rowScanner) (*models.Rollout, error) {
+	var r models.Rollout
+	var stepsJSON, baseWeightsJSON []byte
+	err := row.Scan(&r.ID, &r.ProxyID, &r.TargetID, &stepsJSON, &baseWeightsJSON, &r.CurrentStep, &r.Status,
+		&r.StepStartedAt, &r.PausedAt, &r.CreatedAt, &r.UpdatedAt, &r.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stepsJSON, &r.Steps); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rollout steps: %w", err)
+	}
+	if err := json.Unmarshal(baseWeightsJSON, &r.BaseWeights); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rollout base weights: %w", err)
+	}
+	return &r, nil
+}
+
+func (s *Storage) queryRollouts(ctx context.Context, query string, args ...any) ([]models.Rollout, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollouts: %w", err)
+	}
+	defer rows.Close()
+
+	rollouts := []models.Rollout{}
+	for rows.Next() {
+		r, err := scanRollout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rollout: %w", err)
+		}
+		rollouts = append(rollouts, *r)
+	}
+	return rollouts, rows.Err()
+}
+
+// GetRollouts returns the rollouts of a proxy, newest first
+func (s *Storage) GetRollouts(ctx context.Context, proxyID string) ([]models.Rollout, error) {
+	return s.queryRollouts(ctx,
+		`SELECT `+rolloutColumns+` FROM rollouts WHERE proxy_id = $1 ORDER BY created_at DESC`,
+		proxyID,
+	)
+}
+
+// GetRunningRollouts returns the rollouts of all proxies that are not paused or finished
+func (s *Storage) GetRunningRollouts(ctx context.Context) ([]models.Rollout, error) {
+	return s.queryRollouts(ctx,
+		`SELECT `+rolloutColumns+` FROM rollouts WHERE status = $1 ORDER BY created_at`,
+		models.RolloutStatusRunning,
+	)
+}
+
+func (s *Storage) GetRollout(ctx context.Context, proxyID, id string) (*models.Rollout, error) {
+	r, err := scanRollout(s.db.QueryRowContext(ctx,
+		`SELECT `+rolloutColumns+` FROM rollouts WHERE id = $1 AND proxy_id = $2`,
+		id, proxyID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRolloutNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rollout: %w", err)
+	}
+	return r, nil
+}
+
+func (s *Storage) CreateRollout(ctx context.Context, r *models.Rollout) error {
+	r.ID = uuid.New().String()
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+
+	stepsJSON, err := json.Marshal(r.Steps)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollout steps: %w", err)
+	}
+	baseWeightsJSON, err := json.Marshal(r.BaseWeights)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollout base weights: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO rollouts (`+rolloutColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		r.ID, r.ProxyID, r.TargetID, stepsJSON, baseWeightsJSON, r.CurrentStep, r.Status,
+		r.StepStartedAt, r.PausedAt, r.CreatedAt, r.UpdatedAt, r.CreatedBy,
+	)
+	if isUniqueViolation(err) {
+		return ErrRolloutActive
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert rollout: %w", err)
+	}
+	return nil
+}
+
+// UpdateRolloutStateWithTx saves the progress of a rollout. The update only applies
+// if the stored rollout is still at prevStatus and prevStep, so concurrent
+// transitions from several instances or the API cannot overwrite each other
+func (s *Storage) UpdateRolloutStateWithTx(ctx context.Context, tx *Tx, r *models.Rollout, prevStatus models.RolloutStatus, prevStep int) error {
+	r.UpdatedAt = time.Now()
+	result, err := tx.tx.ExecContext(ctx,
+		`UPDATE rollouts SET current_step = $1, status = $2, step_started_at = $3, paused_at = $4, updated_at = $5
+		WHERE id = $6 AND status = $7 AND current_step = $8`,
+		r.CurrentStep, r.Status, r.StepStartedAt, r.PausedAt, r.UpdatedAt, r.ID, prevStatus, prevStep,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrRolloutChanged
+	}
+	return nil
+}
+
+// AbortTargetRolloutsWithTx aborts the active rollouts of a target
+func (s *Storage) AbortTargetRolloutsWithTx(ctx context.Context, tx *Tx, proxyID, targetID string) error {
+	_, err := tx.tx.ExecContext(ctx,
+		`UPDATE rollouts SET status = $1, paused_at = NULL, updated_at = $2
+		WHERE proxy_id = $3 AND target_id = $4 AND status IN ($5, $6)`,
+		models.RolloutStatusAborted, time.Now(), proxyID, targetID,
+		models.RolloutStatusRunning, models.RolloutStatusPaused,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to abort rollouts: %w", err)
+	}
+	return nil
+}
@@ -83,6 +83,183 @@ func (s *Storage) UpdateProxyConditionWithTx(ctx context.Context, tx *Tx, proxyI
 	return err
 }
 
+func (s *Storage) UpdateProxyAssignmentWithTx(ctx context.Context, tx *Tx, proxyID string, assignment *models.Assignment) error {
+	assignmentJSON, err := nullableJSON(assignment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal assignment: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET assignment = $1, updated_at = $2 WHERE id = $3`,
+		assignmentJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyScheduleWithTx(ctx context.Context, tx *Tx, proxyID string, schedule *models.Schedule) error {
+	scheduleJSON, err := nullableJSON(schedule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET schedule = $1, updated_at = $2 WHERE id = $3`,
+		scheduleJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyBanditWithTx(ctx context.Context, tx *Tx, proxyID string, bandit *models.BanditSettings) error {
+	banditJSON, err := nullableJSON(bandit)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bandit: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET bandit = $1, updated_at = $2 WHERE id = $3`,
+		banditJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyStrategyWithTx(ctx context.Context, tx *Tx, proxyID string, strategy string) error {
+	_, err := tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET strategy = $1, updated_at = $2 WHERE id = $3`,
+		strategy, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyMirrorWithTx(ctx context.Context, tx *Tx, proxyID string, mirror *models.MirrorSettings) error {
+	mirrorJSON, err := nullableJSON(mirror)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET mirror = $1, updated_at = $2 WHERE id = $3`,
+		mirrorJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyGuardrailsWithTx(ctx context.Context, tx *Tx, proxyID string, guardrails *models.GuardrailSettings) error {
+	guardrailsJSON, err := nullableJSON(guardrails)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guardrails: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET guardrails = $1, updated_at = $2 WHERE id = $3`,
+		guardrailsJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyOutlierDetectionWithTx(ctx context.Context, tx *Tx, proxyID string, outlierDetection *models.OutlierDetection) error {
+	outlierDetectionJSON, err := nullableJSON(outlierDetection)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outlier detection: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET outlier_detection = $1, updated_at = $2 WHERE id = $3`,
+		outlierDetectionJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyRetryWithTx(ctx context.Context, tx *Tx, proxyID string, retry *models.RetryPolicy) error {
+	retryJSON, err := nullableJSON(retry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retry policy: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET retry_policy = $1, updated_at = $2 WHERE id = $3`,
+		retryJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyTransportWithTx(ctx context.Context, tx *Tx, proxyID string, transport *models.TransportSettings) error {
+	transportJSON, err := nullableJSON(transport)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transport settings: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET transport = $1, updated_at = $2 WHERE id = $3`,
+		transportJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyHeadersWithTx(ctx context.Context, tx *Tx, proxyID string, headers *models.HeaderRules) error {
+	headersJSON, err := nullableJSON(headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal header rules: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET headers = $1, updated_at = $2 WHERE id = $3`,
+		headersJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyCookieWithTx(ctx context.Context, tx *Tx, proxyID string, cookie *models.CookiePolicy) error {
+	cookieJSON, err := nullableJSON(cookie)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cookie policy: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET cookie_policy = $1, updated_at = $2 WHERE id = $3`,
+		cookieJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+func (s *Storage) UpdateProxyConsentWithTx(ctx context.Context, tx *Tx, proxyID string, consent *models.ConsentPolicy) error {
+	consentJSON, err := nullableJSON(consent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consent policy: %w", err)
+	}
+
+	_, err = tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET consent_policy = $1, updated_at = $2 WHERE id = $3`,
+		consentJSON, time.Now(), proxyID,
+	)
+	return err
+}
+
+// UpdateTargetWeightsWithTx changes the weights of existing targets, keeping their IDs
+func (s *Storage) UpdateTargetWeightsWithTx(ctx context.Context, tx *Tx, proxyID string, weights map[string]float64) error {
+	for targetID, weight := range weights {
+		_, err := tx.tx.ExecContext(ctx,
+			`UPDATE targets SET weight = $1 WHERE id = $2 AND proxy_id = $3`,
+			weight, targetID, proxyID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeactivateTargetWithTx takes a target out of traffic until it is activated again
+func (s *Storage) DeactivateTargetWithTx(ctx context.Context, tx *Tx, proxyID, targetID string) error {
+	_, err := tx.tx.ExecContext(ctx,
+		`UPDATE targets SET is_active = false WHERE id = $1 AND proxy_id = $2`,
+		targetID, proxyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate target: %w", err)
+	}
+	return nil
+}
+
 func (s *Storage) SaveVisit(ctx context.Context, visit *models.Visit) error {
 	visit.ID = uuid.New().String()
 	visit.CreatedAt = time.Now()
@@ -110,24 +287,8 @@ func (s *Storage) UpdateTargets(ctx context.Context, proxyID string, targets []m
 	}
 
 	// Insert new targets
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO targets (id, proxy_id, url, weight, is_active)
-		VALUES ($1, $2, $3, $4, $5)
-	`)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
 	for _, target := range targets {
-		_, err = stmt.ExecContext(ctx,
-			target.ID,
-			proxyID,
-			target.URL,
-			target.Weight,
-			target.IsActive,
-		)
-		if err != nil {
+		if err := insertTarget(ctx, tx, proxyID, target); err != nil {
 			return err
 		}
 	}
@@ -147,12 +308,7 @@ func (s *Storage) UpdateTargetsWithTx(ctx context.Context, tx *Tx, proxyID strin
 
 	// Insert new targets
 	for _, target := range targets {
-		_, err = tx.tx.ExecContext(ctx,
-			`INSERT INTO targets (id, proxy_id, url, weight, is_active)
-			VALUES ($1, $2, $3, $4, $5)`,
-			target.ID, proxyID, target.URL, target.Weight, target.IsActive,
-		)
-		if err != nil {
+		if err := insertTarget(ctx, tx.tx, proxyID, target); err != nil {
 			return err
 		}
 	}
@@ -163,7 +319,7 @@ func (s *Storage) UpdateTargetsWithTx(ctx context.Context, tx *Tx, proxyID strin
 func (s *Storage) GetProxies(ctx context.Context) ([]proxy.Config, error) {
 	var proxies []proxy.Config
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, listen_url, mode, condition, tags
+		`SELECT `+proxyColumns+`
 		FROM proxies ORDER BY created_at DESC`,
 	)
 	if err != nil {
@@ -172,24 +328,19 @@ func (s *Storage) GetProxies(ctx context.Context) ([]proxy.Config, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var p models.Proxy
-		var conditionJSON []byte
-		if err := rows.Scan(&p.ID, &p.ListenURL, &p.Mode, &conditionJSON, pq.Array(&p.Tags)); err != nil {
+		p, err := scanProxy(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan proxy: %w", err)
 		}
-		if len(conditionJSON) > 0 {
-			p.Condition = &models.RouteCondition{}
-			if err := json.Unmarshal(conditionJSON, p.Condition); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal condition: %w", err)
-			}
-		}
-		proxies = append(proxies, proxy.Config{
-			ID:        p.ID,
-			ListenURL: p.ListenURL,
-			Mode:      models.ProxyMode(p.Mode),
-			Condition: (*proxy.Condition)(p.Condition),
-			Tags:      p.Tags,
-		})
+		proxies = append(proxies, proxyConfig(p))
 	}
 	return proxies, nil
 }
+
+func (s *Storage) UpdateProxyTrustedProxiesWithTx(ctx context.Context, tx *Tx, proxyID string, trustedProxies []string) error {
+	_, err := tx.tx.ExecContext(ctx,
+		`UPDATE proxies SET trusted_proxies = $1, updated_at = $2 WHERE id = $3`,
+		pq.Array(trustedProxies), time.Now(), proxyID,
+	)
+	return err
+}
@@ -2,7 +2,6 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/lib/pq"
@@ -57,10 +56,10 @@ func (s *Storage) GetTags(proxyID string) []string {
 
 func (s *Storage) GetProxiesByTags(ctx context.Context, tags []string) ([]*models.Proxy, error) {
 	query := `
-		SELECT DISTINCT p.id, p.listen_url, p.mode, p.condition, p.tags, p.created_at, p.updated_at
-		FROM proxies p
+		SELECT ` + proxyColumns + `
+		FROM proxies
 		WHERE tags @> $1
-		ORDER BY p.created_at DESC
+		ORDER BY created_at DESC
 	`
 
 	rows, err := s.db.QueryContext(ctx, query, pq.Array(tags))
@@ -71,27 +70,11 @@ func (s *Storage) GetProxiesByTags(ctx context.Context, tags []string) ([]*model
 
 	var proxies []*models.Proxy
 	for rows.Next() {
-		var proxy models.Proxy
-		var conditionJSON []byte
-		if err := rows.Scan(
-			&proxy.ID,
-			&proxy.ListenURL,
-			&proxy.Mode,
-			&conditionJSON,
-			pq.Array(&proxy.Tags),
-			&proxy.CreatedAt,
-			&proxy.UpdatedAt,
-		); err != nil {
+		proxy, err := scanProxy(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan proxy: %w", err)
 		}
-
-		if len(conditionJSON) > 0 {
-			if err := json.Unmarshal(conditionJSON, &proxy.Condition); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal condition: %w", err)
-			}
-		}
-
-		proxies = append(proxies, &proxy)
+		proxies = append(proxies, proxy)
 	}
 
 	return proxies, nil
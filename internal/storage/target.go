@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ab-testing-service/internal/models"
+)
+
+const targetColumns = `id, proxy_id, url, weight, is_active, is_control, health_check, headers, path_rewrite`
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertTarget stores a target of a proxy
+func insertTarget(ctx context.Context, db execer, proxyID string, target models.Target) error {
+	healthCheckJSON, err := nullableJSON(target.HealthCheck)
+	if err != nil {
+		return fmt.Errorf("failed to marshal health check: %w", err)
+	}
+	headersJSON, err := nullableJSON(target.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal header rules: %w", err)
+	}
+	pathRewriteJSON, err := nullableJSON(target.PathRewrite)
+	if err != nil {
+		return fmt.Errorf("failed to marshal path rewrite: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO targets (`+targetColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		target.ID, proxyID, target.URL, target.Weight, target.IsActive, target.IsControl, healthCheckJSON, headersJSON, pathRewriteJSON,
+	)
+	return err
+}
+
+func scanTarget(row rowScanner) (models.Target, error) {
+	var target models.Target
+	var healthCheckJSON, headersJSON, pathRewriteJSON []byte
+	err := row.Scan(&target.ID, &target.ProxyID, &target.URL, &target.Weight, &target.IsActive, &target.IsControl,
+		&healthCheckJSON, &headersJSON, &pathRewriteJSON)
+	if err != nil {
+		return target, err
+	}
+	if target.HealthCheck, err = unmarshalNullable[models.HealthCheck](healthCheckJSON); err != nil {
+		return target, fmt.Errorf("failed to unmarshal health check: %w", err)
+	}
+	if target.Headers, err = unmarshalNullable[models.HeaderRules](headersJSON); err != nil {
+		return target, fmt.Errorf("failed to unmarshal header rules: %w", err)
+	}
+	if target.PathRewrite, err = unmarshalNullable[models.PathRewrite](pathRewriteJSON); err != nil {
+		return target, fmt.Errorf("failed to unmarshal path rewrite: %w", err)
+	}
+	return target, nil
+}
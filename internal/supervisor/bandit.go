@@ -0,0 +1,118 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+)
+
+const (
+	defaultBanditInterval = 5 * time.Minute
+	// Smaller weight changes are not worth a new proxy and history entry
+	minBanditWeightChange = 0.005
+)
+
+// banditReweight is the state recorded in proxy_changes for an automatic reweighting
+type banditReweight struct {
+	Targets []proxy.Target              `json:"targets"`
+	Arms    map[string]models.BanditArm `json:"arms"`
+}
+
+func (s *Supervisor) banditInterval() time.Duration {
+	if s.config.Bandit.Interval > 0 {
+		return s.config.Bandit.Interval
+	}
+	return defaultBanditInterval
+}
+
+// reweightBandits recomputes the weights of every proxy running in bandit mode
+func (s *Supervisor) reweightBandits(ctx context.Context) {
+	s.mutex.RLock()
+	var configs []proxy.Config
+	for _, instance := range s.proxies {
+		cfg := instance.Proxy.Config
+		if !cfg.IsBandit() {
+			continue
+		}
+		cfg.Targets = instance.Proxy.Targets()
+		configs = append(configs, cfg)
+	}
+	s.mutex.RUnlock()
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, cfg := range configs {
+		if err := s.reweightBandit(ctx, cfg, rng); err != nil {
+			log.Printf("Failed to reweight bandit for proxy %s: %v", cfg.ID, err)
+		}
+	}
+}
+
+func (s *Supervisor) reweightBandit(ctx context.Context, cfg proxy.Config, rng *rand.Rand) error {
+	// Only one instance reweights a proxy per interval
+	acquired, err := s.storage.AcquireLock(ctx, "bandit:"+cfg.ID, s.banditInterval()*9/10)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+
+	counters, err := s.storage.GetBanditCounters(ctx, cfg.ID)
+	if err != nil {
+		return err
+	}
+
+	// Inactive targets get no traffic and keep their weights
+	var active []int
+	var activeCounters []models.BanditCounters
+	for i, target := range cfg.Targets {
+		if target.IsActive {
+			active = append(active, i)
+			activeCounters = append(activeCounters, counters[target.ID])
+		}
+	}
+	if len(active) < 2 {
+		return nil
+	}
+
+	var minExploration float64
+	if cfg.Bandit != nil {
+		minExploration = cfg.Bandit.MinExploration
+	}
+	arms := proxy.ThompsonWeights(activeCounters, minExploration, rng)
+
+	previous := cfg.Targets
+	targets := make([]proxy.Target, len(previous))
+	copy(targets, previous)
+
+	total := 0.0
+	for _, i := range active {
+		total += previous[i].Weight
+	}
+
+	changed := false
+	reweight := banditReweight{Targets: targets, Arms: make(map[string]models.BanditArm, len(active))}
+	for j, i := range active {
+		// Compare against the current share of the active targets
+		current := 0.0
+		if total > 0 {
+			current = previous[i].Weight / total
+		}
+		if math.Abs(arms[j].Weight-current) >= minBanditWeightChange {
+			changed = true
+		}
+		targets[i].Weight = arms[j].Weight
+		reweight.Arms[targets[i].ID] = arms[j]
+	}
+	if !changed {
+		return nil
+	}
+
+	return s.applyWeights(ctx, cfg, targets, models.ChangeTypeBanditReweight, reweight, nil, nil)
+}
@@ -4,17 +4,43 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/ab-testing-service/internal/proxy"
 )
 
+// VirtualHostHandler routes requests to proxies by host. The host table is copied
+// on every change and swapped atomically, so requests never wait for updates
 type VirtualHostHandler struct {
-	proxies map[string]*proxy.Proxy
+	proxies atomic.Pointer[map[string]*proxy.Proxy]
+}
+
+func newVirtualHostHandler() *VirtualHostHandler {
+	vh := &VirtualHostHandler{}
+	vh.proxies.Store(&map[string]*proxy.Proxy{})
+	return vh
+}
+
+func (vh *VirtualHostHandler) lookup(host string) (*proxy.Proxy, bool) {
+	p, ok := (*vh.proxies.Load())[host]
+	return p, ok
+}
+
+// update applies fn to a copy of the host table and swaps it in, callers
+// hold the supervisor's lock so updates are not lost
+func (vh *VirtualHostHandler) update(fn func(proxies map[string]*proxy.Proxy)) {
+	current := *vh.proxies.Load()
+	proxies := make(map[string]*proxy.Proxy, len(current)+1)
+	for host, p := range current {
+		proxies[host] = p
+	}
+	fn(proxies)
+	vh.proxies.Store(&proxies)
 }
 
 func (vh *VirtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	host := strings.Split(r.Host, ":")[0]
-	if p, ok := vh.proxies[host]; ok {
+	host, _, _ := strings.Cut(r.Host, ":")
+	if p, ok := vh.lookup(host); ok {
 		p.ServeHTTP(w, r)
 	} else {
 		http.Error(w, "Host not found", http.StatusNotFound)
@@ -33,29 +59,30 @@ func (s *Supervisor) CreateProxy(cfg proxy.Config) error {
 
 	// Check if proxy with same host already exists
 	if s.virtualHandler != nil {
-		if _, exists := s.virtualHandler.proxies[host]; exists {
+		if _, exists := s.virtualHandler.lookup(host); exists {
 			return fmt.Errorf("proxy with host %s already exists", host)
 		}
 	}
 
-	p, err := proxy.NewProxy(cfg)
+	p, err := s.newProxy(cfg)
 	if err != nil {
 		return err
 	}
 
 	instance := &ProxyInstance{
-		Proxy: p,
+		Proxy:   p,
+		Started: true,
 	}
 
 	// Initialize virtual host handler if not exists
 	if s.virtualHandler == nil {
-		s.virtualHandler = &VirtualHostHandler{
-			proxies: make(map[string]*proxy.Proxy),
-		}
+		s.virtualHandler = newVirtualHostHandler()
 	}
 
 	// Add proxy to virtual host handler
-	s.virtualHandler.proxies[host] = p
+	s.virtualHandler.update(func(proxies map[string]*proxy.Proxy) {
+		proxies[host] = p
+	})
 	s.proxies[cfg.ID] = instance
 
 	// Start the server if not already running
@@ -0,0 +1,78 @@
+package supervisor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+)
+
+func newBenchmarkProxy(b *testing.B, host string) *proxy.Proxy {
+	p, err := proxy.NewProxy(proxy.Config{
+		ID:        host,
+		ListenURL: host + ":80",
+		Mode:      models.ProxyModeRedirect,
+		Targets: []proxy.Target{
+			{ID: "control", URL: "http://control.example.com", Weight: 0.5, IsActive: true, IsControl: true},
+			{ID: "variant", URL: "http://variant.example.com", Weight: 0.5, IsActive: true},
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return p
+}
+
+// BenchmarkVirtualHostHandler serves redirects from many goroutines, optionally
+// while another goroutine keeps replacing proxies the way UpdateProxyTargets does
+func BenchmarkVirtualHostHandler(b *testing.B) {
+	vh := newVirtualHostHandler()
+	for i := 0; i < 16; i++ {
+		host := fmt.Sprintf("site%d.local", i)
+		p := newBenchmarkProxy(b, host)
+		vh.update(func(proxies map[string]*proxy.Proxy) { proxies[host] = p })
+	}
+
+	run := func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetParallelism(16)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			r := httptest.NewRequest(http.MethodGet, "http://site3.local/", nil)
+			for pb.Next() {
+				w := httptest.NewRecorder()
+				vh.ServeHTTP(w, r)
+				if w.Code != http.StatusMovedPermanently {
+					b.Errorf("unexpected status %d", w.Code)
+					return
+				}
+			}
+		})
+	}
+
+	b.Run("idle", run)
+	b.Run("updating", func(b *testing.B) {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				p := newBenchmarkProxy(b, "site3.local")
+				vh.update(func(proxies map[string]*proxy.Proxy) { proxies["site3.local"] = p })
+			}
+		}()
+		run(b)
+		close(done)
+		wg.Wait()
+	})
+}
@@ -0,0 +1,31 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// emitEvent logs an event and sends it to Kafka next to the statistics,
+// keyed by proxy ID. Events carry an "event" field that stats messages lack
+func (s *Supervisor) emitEvent(ctx context.Context, proxyID string, event interface{}) {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling event for proxy %s: %v", proxyID, err)
+		return
+	}
+	log.Printf("Proxy %s event: %s", proxyID, eventJSON)
+
+	if s.kafkaWriter == nil {
+		return
+	}
+	msg := kafka.Message{
+		Key:   []byte(proxyID),
+		Value: eventJSON,
+	}
+	if err := s.kafkaWriter.WriteMessages(ctx, msg); err != nil {
+		log.Printf("Error sending event for proxy %s: %v", proxyID, err)
+	}
+}
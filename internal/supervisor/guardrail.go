@@ -0,0 +1,165 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+	"github.com/ab-testing-service/internal/storage"
+)
+
+const (
+	defaultGuardrailInterval = time.Minute
+
+	guardrailEventRollback = "guardrail_rollback"
+	guardrailRuleErrors    = "error_ratio"
+	guardrailRuleLatency   = "latency_ratio"
+)
+
+func (s *Supervisor) guardrailInterval() time.Duration {
+	if s.config.Guardrails.Interval > 0 {
+		return s.config.Guardrails.Interval
+	}
+	return defaultGuardrailInterval
+}
+
+// checkGuardrails evaluates the guardrails of every proxy that has them
+func (s *Supervisor) checkGuardrails(ctx context.Context) {
+	s.mutex.RLock()
+	var configs []proxy.Config
+	for _, instance := range s.proxies {
+		if instance.Proxy == nil || !instance.Proxy.Config.Guardrails.Enabled() {
+			continue
+		}
+		cfg := instance.Proxy.Config
+		cfg.Targets = instance.Proxy.Targets()
+		configs = append(configs, cfg)
+	}
+	s.mutex.RUnlock()
+
+	for _, cfg := range configs {
+		if err := s.checkGuardrail(ctx, cfg); err != nil {
+			log.Printf("Failed to check guardrails for proxy %s: %v", cfg.ID, err)
+		}
+	}
+}
+
+func (s *Supervisor) checkGuardrail(ctx context.Context, cfg proxy.Config) error {
+	// Only one instance evaluates a proxy per interval
+	acquired, err := s.storage.AcquireLock(ctx, "guardrails:"+cfg.ID, s.guardrailInterval()*9/10)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+
+	samples, err := s.storage.GetGuardrailSamples(ctx, cfg.ID, cfg.Guardrails.Window())
+	if err != nil {
+		return err
+	}
+
+	for _, event := range evaluateGuardrails(cfg.Guardrails, cfg.Targets, samples) {
+		targets, err := s.rollbackTarget(ctx, cfg, event)
+		if err != nil {
+			return err
+		}
+		cfg.Targets = targets
+	}
+	return nil
+}
+
+// evaluateGuardrails returns a rollback event for every target that breaks a rule
+func evaluateGuardrails(g *models.GuardrailSettings, targets []proxy.Target, samples map[string]models.GuardrailSample) []models.GuardrailEvent {
+	minRequests := g.MinRequests
+	if minRequests < 1 {
+		minRequests = 1
+	}
+
+	// Latency is judged against the control target, which is never rolled back itself
+	var controlID string
+	var controlP95 time.Duration
+	for _, target := range targets {
+		if target.IsControl && target.IsActive {
+			controlID = target.ID
+			if sample := samples[target.ID]; sample.Requests >= minRequests {
+				controlP95 = proxy.LatencyPercentile(sample.Latency, 0.95)
+			}
+		}
+	}
+
+	// Rolling back every weighted target would leave no traffic to send anywhere
+	weighted := 0
+	for _, target := range targets {
+		if target.IsActive && target.Weight > 0 {
+			weighted++
+		}
+	}
+
+	var events []models.GuardrailEvent
+	for _, target := range targets {
+		if target.ID == controlID || !target.IsActive || target.Weight == 0 || weighted <= 1 {
+			continue
+		}
+		sample := samples[target.ID]
+		if sample.Requests < minRequests {
+			continue
+		}
+
+		event := models.GuardrailEvent{
+			Event:    guardrailEventRollback,
+			TargetID: target.ID,
+			Requests: sample.Requests,
+		}
+		errorRatio := float64(sample.Errors) / float64(sample.Requests)
+		if g.MaxErrorRatio > 0 && errorRatio > g.MaxErrorRatio {
+			event.Rule, event.Value, event.Threshold = guardrailRuleErrors, errorRatio, g.MaxErrorRatio
+		} else if g.MaxLatencyRatio > 0 && controlP95 > 0 {
+			p95 := proxy.LatencyPercentile(sample.Latency, 0.95)
+			latencyRatio := float64(p95) / float64(controlP95)
+			if latencyRatio > g.MaxLatencyRatio {
+				event.Rule, event.Value, event.Threshold = guardrailRuleLatency, latencyRatio, g.MaxLatencyRatio
+			}
+		}
+		if event.Rule == "" {
+			continue
+		}
+		events = append(events, event)
+		weighted--
+	}
+	return events
+}
+
+// rollbackTarget moves the weight of a target that tripped a guardrail to zero
+// and deactivates it, so the bandit does not give it traffic again. It stays
+// out until it is activated again through the API
+func (s *Supervisor) rollbackTarget(ctx context.Context, cfg proxy.Config, event models.GuardrailEvent) ([]proxy.Target, error) {
+	targets := make([]proxy.Target, len(cfg.Targets))
+	copy(targets, cfg.Targets)
+	for i := range targets {
+		if targets[i].ID == event.TargetID {
+			event.PreviousWeight = targets[i].Weight
+			targets[i].Weight = 0
+			targets[i].IsActive = false
+		}
+	}
+	event.ProxyID = cfg.ID
+	event.CreatedAt = time.Now()
+
+	err := s.applyWeights(ctx, cfg, targets, models.ChangeTypeGuardrailRollback, event, nil, func(tx *storage.Tx) error {
+		if err := s.storage.DeactivateTargetWithTx(ctx, tx, cfg.ID, event.TargetID); err != nil {
+			return err
+		}
+		// A rollout of the target would ramp it up again
+		return s.storage.AbortTargetRolloutsWithTx(ctx, tx, cfg.ID, event.TargetID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.emitEvent(ctx, cfg.ID, event)
+	return targets, nil
+}
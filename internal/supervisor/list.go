@@ -9,7 +9,11 @@ import (
 func (s *Supervisor) GetProxy(id string) *proxy.Proxy {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	return s.proxies[id].Proxy
+	instance, ok := s.proxies[id]
+	if !ok {
+		return nil
+	}
+	return instance.Proxy
 }
 
 func (s *Supervisor) ListProxies(sortBy string, sortDesc bool) []proxy.Config {
@@ -19,14 +23,11 @@ func (s *Supervisor) ListProxies(sortBy string, sortDesc bool) []proxy.Config {
 	var configs []proxy.Config
 	for id, p := range s.proxies {
 		tags := s.storage.GetTags(id)
-		configs = append(configs, proxy.Config{
-			ID:        id,
-			ListenURL: p.Proxy.ListenURL,
-			Mode:      p.Proxy.Mode,
-			Targets:   p.Proxy.Targets,
-			Condition: p.Proxy.Config.Condition,
-			Tags:      tags,
-		})
+		cfg := p.Proxy.Config
+		cfg.ID = id
+		cfg.Targets = p.Proxy.Targets()
+		cfg.Tags = tags
+		configs = append(configs, cfg)
 	}
 
 	// Sort the configs based on the sortBy parameter
@@ -0,0 +1,248 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ab-testing-service/internal/models"
+	"github.com/ab-testing-service/internal/proxy"
+	"github.com/ab-testing-service/internal/storage"
+)
+
+const defaultRolloutInterval = 30 * time.Second
+
+var (
+	ErrRolloutNotRunning = errors.New("rollout is not running")
+	ErrRolloutNotPaused  = errors.New("rollout is not paused")
+	ErrRolloutFinished   = errors.New("rollout is already finished")
+	ErrInvalidRollout    = errors.New("invalid rollout")
+)
+
+// rolloutChange is the state recorded in proxy_changes for a rollout step or abort
+type rolloutChange struct {
+	RolloutID string         `json:"rollout_id"`
+	Step      int            `json:"step"`
+	Weight    float64        `json:"weight"`
+	Targets   []proxy.Target `json:"targets"`
+}
+
+func (s *Supervisor) rolloutInterval() time.Duration {
+	if s.config.Rollout.Interval > 0 {
+		return s.config.Rollout.Interval
+	}
+	return defaultRolloutInterval
+}
+
+// StartRollout stores a new rollout and applies its first step
+func (s *Supervisor) StartRollout(ctx context.Context, r *models.Rollout) error {
+	cfg, err := s.runningConfig(r.ProxyID)
+	if err != nil {
+		return err
+	}
+	if _, err := rolloutWeights(cfg.Targets, r.TargetID, nil, 0); err != nil {
+		return err
+	}
+
+	r.BaseWeights = make(map[string]float64)
+	for _, target := range cfg.Targets {
+		if target.ID != r.TargetID {
+			r.BaseWeights[target.ID] = target.Weight
+		}
+	}
+	r.CurrentStep = -1
+	r.Status = models.RolloutStatusRunning
+	if err := s.storage.CreateRollout(ctx, r); err != nil {
+		return err
+	}
+
+	return s.applyRolloutStep(ctx, r, 0, r.CreatedBy)
+}
+
+// advanceRollouts moves every running rollout whose current step has elapsed to the next one
+func (s *Supervisor) advanceRollouts(ctx context.Context) {
+	rollouts, err := s.storage.GetRunningRollouts(ctx)
+	if err != nil {
+		log.Printf("Failed to get running rollouts: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range rollouts {
+		r := &rollouts[i]
+		if next := r.NextStepAt(); next != nil && now.Before(*next) {
+			continue
+		}
+		// Another instance or the API got there first
+		err := s.applyRolloutStep(ctx, r, r.CurrentStep+1, nil)
+		if err != nil && !errors.Is(err, storage.ErrRolloutChanged) {
+			log.Printf("Failed to advance rollout %s of proxy %s: %v", r.ID, r.ProxyID, err)
+		}
+	}
+}
+
+// applyRolloutStep sets the canary weight of a step, completing the rollout after the last one
+func (s *Supervisor) applyRolloutStep(ctx context.Context, r *models.Rollout, step int, userID *string) error {
+	prevStatus, prevStep := r.Status, r.CurrentStep
+
+	if step >= len(r.Steps) {
+		r.Status = models.RolloutStatusCompleted
+		return s.saveRolloutState(ctx, r, prevStatus, prevStep)
+	}
+
+	cfg, err := s.runningConfig(r.ProxyID)
+	if err != nil {
+		return err
+	}
+	weight := r.Steps[step].Weight
+	targets, err := rolloutWeights(cfg.Targets, r.TargetID, r.BaseWeights, weight)
+	if err != nil {
+		// The canary target was removed or deactivated, the plan cannot continue
+		r.Status = models.RolloutStatusAborted
+		if saveErr := s.saveRolloutState(ctx, r, prevStatus, prevStep); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+
+	now := time.Now()
+	r.CurrentStep = step
+	r.StepStartedAt = &now
+	change := rolloutChange{RolloutID: r.ID, Step: step, Weight: weight, Targets: targets}
+	return s.applyWeights(ctx, cfg, targets, models.ChangeTypeRolloutStep, change, userID, func(tx *storage.Tx) error {
+		return s.storage.UpdateRolloutStateWithTx(ctx, tx, r, prevStatus, prevStep)
+	})
+}
+
+// PauseRollout holds the current step until the rollout is resumed
+func (s *Supervisor) PauseRollout(ctx context.Context, proxyID, id string) (*models.Rollout, error) {
+	r, err := s.storage.GetRollout(ctx, proxyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != models.RolloutStatusRunning {
+		return nil, ErrRolloutNotRunning
+	}
+
+	now := time.Now()
+	r.Status = models.RolloutStatusPaused
+	r.PausedAt = &now
+	if err := s.saveRolloutState(ctx, r, models.RolloutStatusRunning, r.CurrentStep); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ResumeRollout continues a paused rollout, the time spent paused does not count towards the step
+func (s *Supervisor) ResumeRollout(ctx context.Context, proxyID, id string) (*models.Rollout, error) {
+	r, err := s.storage.GetRollout(ctx, proxyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != models.RolloutStatusPaused {
+		return nil, ErrRolloutNotPaused
+	}
+
+	if r.StepStartedAt != nil && r.PausedAt != nil {
+		started := r.StepStartedAt.Add(time.Since(*r.PausedAt))
+		r.StepStartedAt = &started
+	}
+	r.Status = models.RolloutStatusRunning
+	r.PausedAt = nil
+	if err := s.saveRolloutState(ctx, r, models.RolloutStatusPaused, r.CurrentStep); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// AbortRollout stops a rollout and takes all traffic away from the canary target
+func (s *Supervisor) AbortRollout(ctx context.Context, proxyID, id string, userID *string) (*models.Rollout, error) {
+	r, err := s.storage.GetRollout(ctx, proxyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Status.IsActive() {
+		return nil, ErrRolloutFinished
+	}
+
+	prevStatus := r.Status
+	r.Status = models.RolloutStatusAborted
+	r.PausedAt = nil
+
+	var targets []proxy.Target
+	cfg, err := s.runningConfig(proxyID)
+	if err == nil && r.CurrentStep >= 0 {
+		targets, err = rolloutWeights(cfg.Targets, r.TargetID, r.BaseWeights, 0)
+	}
+	if err != nil || targets == nil {
+		// Nothing to roll back
+		if err := s.saveRolloutState(ctx, r, prevStatus, r.CurrentStep); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
+	change := rolloutChange{RolloutID: r.ID, Step: r.CurrentStep, Targets: targets}
+	err = s.applyWeights(ctx, cfg, targets, models.ChangeTypeRolloutAbort, change, userID, func(tx *storage.Tx) error {
+		return s.storage.UpdateRolloutStateWithTx(ctx, tx, r, prevStatus, r.CurrentStep)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *Supervisor) saveRolloutState(ctx context.Context, r *models.Rollout, prevStatus models.RolloutStatus, prevStep int) error {
+	tx, err := s.storage.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.storage.UpdateRolloutStateWithTx(ctx, tx, r, prevStatus, prevStep); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rolloutWeights gives the canary target its share and splits the rest between the
+// other active targets in proportion to their base weights
+func rolloutWeights(current []proxy.Target, canaryID string, base map[string]float64, weight float64) ([]proxy.Target, error) {
+	targets := make([]proxy.Target, len(current))
+	copy(targets, current)
+
+	canary := -1
+	var others []int
+	total := 0.0
+	for i, target := range targets {
+		switch {
+		case target.ID == canaryID:
+			canary = i
+		case target.IsActive:
+			others = append(others, i)
+			// Targets added after the rollout started keep their own weight
+			if w, ok := base[target.ID]; ok {
+				targets[i].Weight = w
+			}
+			total += targets[i].Weight
+		}
+	}
+	if canary < 0 || !targets[canary].IsActive {
+		return nil, fmt.Errorf("%w: canary target %s is not an active target of the proxy", ErrInvalidRollout, canaryID)
+	}
+	if len(others) == 0 {
+		return nil, fmt.Errorf("%w: proxy has no other active target to share traffic with", ErrInvalidRollout)
+	}
+
+	targets[canary].Weight = weight
+	for _, i := range others {
+		if total > 0 {
+			targets[i].Weight = targets[i].Weight / total * (1 - weight)
+		} else {
+			targets[i].Weight = (1 - weight) / float64(len(others))
+		}
+	}
+	return targets, nil
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+
+	"github.com/ab-testing-service/internal/models"
 )
 
 func (s *Supervisor) collectStatistics(ctx context.Context) {
@@ -32,6 +34,9 @@ func (s *Supervisor) collectStatistics(ctx context.Context) {
 			"timestamp":    time.Now().Unix(),
 			"target_stats": currentStats,
 		}
+		if shadowStats := stats.GetShadowStats(); len(shadowStats) > 0 {
+			statsMsg["shadow_stats"] = shadowStats
+		}
 
 		// Send stats to Kafka if configured
 		if s.kafkaWriter != nil {
@@ -50,6 +55,55 @@ func (s *Supervisor) collectStatistics(ctx context.Context) {
 			}
 		}
 
+		// Count the exposures for the bandit before they are reset. Exposures are
+		// exposed requests, not unique users, so conversions must be reported per
+		// request for the rates to be comparable between targets
+		if instance.Proxy.Config.IsBandit() {
+			exposures := make(map[string]int64, len(currentStats))
+			for targetID, targetStats := range currentStats {
+				exposures[targetID] = targetStats.RequestCount
+			}
+			if err := s.storage.AddBanditExposures(ctx, instance.Proxy.Config.ID, exposures); err != nil {
+				log.Printf("Error saving bandit exposures for proxy %s: %v", instance.Proxy.Config.ID, err)
+			}
+		}
+
+		// Guardrails are evaluated on the samples of all instances
+		if guardrails := instance.Proxy.Config.Guardrails; guardrails.Enabled() {
+			samples := make(map[string]models.GuardrailSample, len(currentStats))
+			for targetID, targetStats := range currentStats {
+				sample := models.GuardrailSample{
+					Requests: targetStats.RequestCount,
+					Errors:   targetStats.ErrorCount + targetStats.ServerErrorCount,
+					Latency:  targetStats.Latency,
+				}
+				for _, count := range targetStats.Cohorts {
+					sample.Requests += count
+				}
+				samples[targetID] = sample
+			}
+			if err := s.storage.AddGuardrailSamples(ctx, instance.Proxy.Config.ID, guardrails.Window(), samples); err != nil {
+				log.Printf("Error saving guardrail samples for proxy %s: %v", instance.Proxy.Config.ID, err)
+			}
+		}
+
+		// Keep the response comparisons until they are queried
+		if diffStats := stats.GetDiffStats(); len(diffStats) > 0 {
+			var summaries []models.DiffSummary
+			var examples []models.ResponseDiff
+			for shadow, d := range diffStats {
+				summaries = append(summaries, models.DiffSummary{
+					Shadow:     shadow,
+					Compared:   d.Compared,
+					Mismatched: d.Mismatched,
+				})
+				examples = append(examples, d.Examples...)
+			}
+			if err := s.storage.AddDiffs(ctx, instance.Proxy.Config.ID, summaries, examples); err != nil {
+				log.Printf("Error saving response diffs for proxy %s: %v", instance.Proxy.Config.ID, err)
+			}
+		}
+
 		// Reset stats after successful sending
 		stats.Reset()
 	}
@@ -2,6 +2,7 @@ package supervisor
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/segmentio/kafka-go"
 
 	"github.com/ab-testing-service/internal/config"
+	"github.com/ab-testing-service/internal/geo"
 	"github.com/ab-testing-service/internal/proxy"
 	"github.com/ab-testing-service/internal/storage"
 )
@@ -28,6 +30,10 @@ type Supervisor struct {
 	pubsub         *proxy.RedisPubSub
 	server         *http.Server
 	virtualHandler *VirtualHostHandler
+	geo            *geo.Resolver
+	holdout        *proxy.Holdout
+	health         *proxy.HealthChecker
+	outliers       *proxy.OutlierDetector
 }
 
 type Config struct {
@@ -42,6 +48,10 @@ func NewSupervisor(cfg Config) *Supervisor {
 		config:      cfg.Config,
 		storage:     cfg.Storage,
 		kafkaWriter: cfg.KafkaWriter,
+		geo:         geo.NewResolver(cfg.Config.GeoIP.Database),
+		holdout:     proxy.NewHoldout(cfg.Config.Holdout.Percentage, cfg.Config.Holdout.Salt),
+		health:      proxy.NewHealthChecker(),
+		outliers:    proxy.NewOutlierDetector(),
 	}
 
 	// Initialize Redis pub/sub with update callback
@@ -56,6 +66,9 @@ func (s *Supervisor) Start(ctx context.Context) {
 		log.Printf("Failed to start Redis subscriber: %v", err)
 	}
 
+	// Reload the GeoIP database when it changes on disk
+	go s.geo.Watch(ctx, s.config.GeoIP.ReloadInterval)
+
 	// Load existing proxies from Postgres
 	configs, err := s.storage.GetProxies(ctx)
 	if err != nil {
@@ -74,6 +87,11 @@ func (s *Supervisor) Start(ctx context.Context) {
 				URL:      t.URL,
 				Weight:   t.Weight,
 				IsActive: t.IsActive,
+
+				IsControl:   t.IsControl,
+				HealthCheck: t.HealthCheck,
+				Headers:     t.Headers,
+				PathRewrite: t.PathRewrite,
 			})
 		}
 		// Save existing proxy configurations to Redis cache
@@ -98,6 +116,94 @@ func (s *Supervisor) Start(ctx context.Context) {
 			}
 		}
 	}()
+
+	// Start bandit reweighting
+	go func() {
+		ticker := time.NewTicker(s.banditInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.reweightBandits(ctx)
+			}
+		}
+	}()
+
+	// Start rollout execution
+	go func() {
+		ticker := time.NewTicker(s.rolloutInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.advanceRollouts(ctx)
+			}
+		}
+	}()
+
+	// Start guardrail evaluation
+	go func() {
+		ticker := time.NewTicker(s.guardrailInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkGuardrails(ctx)
+			}
+		}
+	}()
+}
+
+// newProxy creates a proxy and wires in the service-wide dependencies. It fails
+// if the overrides cannot be loaded: without the secret neither override tokens
+// nor sticky cookies would be accepted, so the running proxy is kept instead
+func (s *Supervisor) newProxy(cfg proxy.Config) (*proxy.Proxy, error) {
+	p, err := proxy.NewProxy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Overrides are kept out of the cached config so the secret is never exposed
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	secret, err := s.storage.GetOverrideSecret(ctx, cfg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load override secret: %w", err)
+	}
+	overrides, err := s.storage.GetOverrides(ctx, cfg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load overrides: %w", err)
+	}
+	p.SetOverrides(secret, overrides)
+
+	p.SetGeoResolver(s.geo)
+	p.SetHoldout(s.holdout)
+	p.SetHealthChecker(s.health)
+	p.SetOutlierDetector(s.outliers)
+	s.health.Sync(cfg.ID, cfg.Targets)
+
+	return p, nil
+}
+
+// Holdout returns the global holdout shared by all proxies
+func (s *Supervisor) Holdout() *proxy.Holdout {
+	return s.holdout
+}
+
+// Health returns the health checker of the targets of all proxies
+func (s *Supervisor) Health() *proxy.HealthChecker {
+	return s.health
+}
+
+// Outliers returns the outlier detector of the targets of all proxies
+func (s *Supervisor) Outliers() *proxy.OutlierDetector {
+	return s.outliers
 }
 
 func (s *Supervisor) Shutdown(ctx context.Context) error {
@@ -114,6 +220,8 @@ func (s *Supervisor) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	s.health.Stop()
 	s.kafkaWriter.Close()
+	s.geo.Close()
 	return lastErr
 }
@@ -6,7 +6,9 @@ import (
 	"log"
 	"strings"
 
+	"github.com/ab-testing-service/internal/models"
 	"github.com/ab-testing-service/internal/proxy"
+	"github.com/ab-testing-service/internal/storage"
 )
 
 func (s *Supervisor) DeleteProxy(id string) error {
@@ -19,12 +21,17 @@ func (s *Supervisor) DeleteProxy(id string) error {
 		// Remove from virtual host handler
 		host := strings.Split(instance.Proxy.Config.ListenURL, ":")[0]
 		if s.virtualHandler != nil {
-			delete(s.virtualHandler.proxies, host)
+			s.virtualHandler.update(func(proxies map[string]*proxy.Proxy) {
+				delete(proxies, host)
+			})
 		}
+		instance.Proxy.Close()
 	}
 
 	// Remove from proxies map
 	delete(s.proxies, id)
+	s.health.Remove(id)
+	s.outliers.Remove(id)
 
 	ctx := context.Background()
 	return s.storage.InvalidateProxyCache(ctx, id)
@@ -32,20 +39,96 @@ func (s *Supervisor) DeleteProxy(id string) error {
 
 // handleProxyUpdate is called when a proxy settings change notification is received
 func (s *Supervisor) handleProxyUpdate(ctx context.Context, proxyID string) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
 	// Get the latest config from storage
 	cfg, err := s.storage.GetProxyConfig(context.Background(), proxyID)
 	if err != nil {
 		return fmt.Errorf("failed to get proxy config: %w", err)
 	}
 
-	// Update the proxy
-	return s.UpdateProxyTargets(ctx, cfg)
+	// The sender has already cached and published the change, only swap the proxy here
+	return s.replaceProxy(cfg)
 }
 
 func (s *Supervisor) UpdateProxyTargets(ctx context.Context, cfg proxy.Config) error {
+	if err := s.replaceProxy(cfg); err != nil {
+		return err
+	}
+
+	if err := s.storage.InvalidateProxyCache(ctx, cfg.ID); err != nil {
+		return fmt.Errorf("failed to invalidate proxy cache: %w", err)
+	}
+
+	if err := s.storage.SaveProxyConfig(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to update proxy config: %w", err)
+	}
+
+	// Publish change to other instances
+	if err := s.pubsub.PublishSettingsChange(ctx, cfg.ID); err != nil {
+		log.Printf("Failed to publish settings change: %v", err)
+	}
+
+	return nil
+}
+
+// ReloadProxy rebuilds a proxy from its running configuration on all instances,
+// picking up settings that are stored outside of the config such as overrides
+func (s *Supervisor) ReloadProxy(ctx context.Context, id string) error {
+	cfg, err := s.runningConfig(id)
+	if err != nil {
+		return err
+	}
+	return s.UpdateProxyTargets(ctx, cfg)
+}
+
+// runningConfig returns the configuration of a running proxy including its targets
+func (s *Supervisor) runningConfig(id string) (proxy.Config, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	instance, exists := s.proxies[id]
+	if !exists || instance.Proxy == nil {
+		return proxy.Config{}, fmt.Errorf("proxy %s not found", id)
+	}
+	cfg := instance.Proxy.Config
+	cfg.Targets = instance.Proxy.Targets()
+	return cfg, nil
+}
+
+// applyWeights stores new target weights together with a proxy_changes entry and
+// applies them on all instances. inTx, if set, runs in the same transaction
+func (s *Supervisor) applyWeights(ctx context.Context, cfg proxy.Config, targets []proxy.Target, changeType models.ChangeType, newState interface{}, userID *string, inTx func(tx *storage.Tx) error) error {
+	weights := make(map[string]float64, len(targets))
+	for _, target := range targets {
+		weights[target.ID] = target.Weight
+	}
+
+	tx, err := s.storage.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.storage.RecordProxyChange(ctx, tx, cfg.ID, changeType, cfg.Targets, newState, userID); err != nil {
+		return fmt.Errorf("failed to record %s: %w", changeType, err)
+	}
+	if err := s.storage.UpdateTargetWeightsWithTx(ctx, tx, cfg.ID, weights); err != nil {
+		return fmt.Errorf("failed to update target weights: %w", err)
+	}
+	if inTx != nil {
+		if err := inTx(tx); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	cfg.Targets = targets
+	return s.UpdateProxyTargets(ctx, cfg)
+}
+
+// replaceProxy rebuilds the running proxy from cfg
+func (s *Supervisor) replaceProxy(cfg proxy.Config) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -62,19 +145,24 @@ func (s *Supervisor) UpdateProxyTargets(ctx context.Context, cfg proxy.Config) e
 	newHost := strings.Split(cfg.ListenURL, ":")[0]
 
 	// Create new proxy with updated config
-	newProxy, err := proxy.NewProxy(cfg)
+	newProxy, err := s.newProxy(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create new proxy: %w", err)
 	}
+	// Keep the connections to targets that did not change and the unflushed stats
+	newProxy.ReuseUpstreams(instance.Proxy)
+	newProxy.ReuseState(instance.Proxy)
 
 	// Update virtual host handler
 	if s.virtualHandler != nil {
-		// Remove old host if it changed
-		if oldHost != "" && oldHost != newHost {
-			delete(s.virtualHandler.proxies, oldHost)
-		}
-		// Add new host
-		s.virtualHandler.proxies[newHost] = newProxy
+		s.virtualHandler.update(func(proxies map[string]*proxy.Proxy) {
+			// Remove old host if it changed
+			if oldHost != "" && oldHost != newHost {
+				delete(proxies, oldHost)
+			}
+			// Add new host
+			proxies[newHost] = newProxy
+		})
 	}
 
 	// Update the instance
@@ -83,18 +171,5 @@ func (s *Supervisor) UpdateProxyTargets(ctx context.Context, cfg proxy.Config) e
 		Started: true,
 	}
 
-	if err := s.storage.InvalidateProxyCache(ctx, cfg.ID); err != nil {
-		return fmt.Errorf("failed to invalidate proxy cache: %w", err)
-	}
-
-	if err := s.storage.SaveProxyConfig(ctx, cfg); err != nil {
-		return fmt.Errorf("failed to update proxy config: %w", err)
-	}
-
-	// Publish change to other instances
-	if err := s.pubsub.PublishSettingsChange(ctx, cfg.ID); err != nil {
-		log.Printf("Failed to publish settings change: %v", err)
-	}
-
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // Schedules may use any IANA timezone, also in images without tzdata
 
 	"github.com/go-redis/redis/v8"
 	"github.com/segmentio/kafka-go"
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add assignment settings (random or hash-based bucketing) to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS assignment JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add trusted proxies (CIDRs allowed to set forwarding headers) to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS trusted_proxies TEXT[] DEFAULT '{}';
+-- +goose StatementEnd
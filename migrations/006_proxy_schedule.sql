@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add activation schedule (start/end and weekly windows) to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS schedule JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add Thompson sampling bandit settings to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS bandit JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add target selection strategy to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS strategy TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
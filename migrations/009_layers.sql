@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create layers of mutually exclusive experiments
+CREATE TABLE IF NOT EXISTS layers (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Each proxy owns a range of buckets in at most one layer
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS layer_id VARCHAR(255) REFERENCES layers(id);
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS layer_bucket_start INTEGER;
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS layer_bucket_end INTEGER;
+CREATE INDEX IF NOT EXISTS idx_proxies_layer_id ON proxies(layer_id);
+
+-- Users outside a proxy's buckets are sent to its control target
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS is_control BOOLEAN NOT NULL DEFAULT false;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Secret used to sign ab_force override tokens, new proxies get one generated by the service.
+-- Existing proxies are backfilled from gen_random_uuid, which uses a cryptographic random source
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS override_secret VARCHAR(255);
+UPDATE proxies SET override_secret = encode(sha256((gen_random_uuid()::text || gen_random_uuid()::text)::bytea), 'hex')
+WHERE override_secret IS NULL;
+ALTER TABLE proxies ALTER COLUMN override_secret SET NOT NULL;
+
+-- Create proxy_overrides table, target_id is not a foreign key because targets are replaced on update
+CREATE TABLE IF NOT EXISTS proxy_overrides (
+    id VARCHAR(255) PRIMARY KEY,
+    proxy_id VARCHAR(255) NOT NULL REFERENCES proxies(id) ON DELETE CASCADE,
+    identity VARCHAR(255) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(255) REFERENCES users(id),
+    UNIQUE (proxy_id, identity)
+);
+
+CREATE INDEX idx_proxy_overrides_proxy_id ON proxy_overrides(proxy_id);
+-- +goose StatementEnd
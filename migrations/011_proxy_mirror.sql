@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add traffic mirroring to shadow targets to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS mirror JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create rollouts table, target_id is not a foreign key because targets are replaced on update
+CREATE TABLE IF NOT EXISTS rollouts (
+    id VARCHAR(255) PRIMARY KEY,
+    proxy_id VARCHAR(255) NOT NULL REFERENCES proxies(id) ON DELETE CASCADE,
+    target_id VARCHAR(255) NOT NULL,
+    steps JSONB NOT NULL,
+    base_weights JSONB NOT NULL DEFAULT '{}',
+    current_step INTEGER NOT NULL DEFAULT -1,
+    status VARCHAR(50) NOT NULL,
+    step_started_at TIMESTAMP WITH TIME ZONE,
+    paused_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(255) REFERENCES users(id)
+);
+
+CREATE INDEX idx_rollouts_proxy_id ON rollouts(proxy_id);
+
+-- A proxy has at most one running or paused rollout
+CREATE UNIQUE INDEX idx_rollouts_active ON rollouts(proxy_id) WHERE status IN ('running', 'paused');
+-- +goose StatementEnd
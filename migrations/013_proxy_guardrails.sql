@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add automatic rollback guardrails to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS guardrails JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add active health checks to targets table
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS health_check JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add passive outlier detection to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS outlier_detection JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add retries on connection errors to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS retry_policy JSONB;
+-- +goose StatementEnd
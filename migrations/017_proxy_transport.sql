@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add target connection settings to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS transport JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,6 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add header rewrite rules to proxies and targets tables
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS headers JSONB;
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS headers JSONB;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add path rewrite rules to targets table
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS path_rewrite JSONB;
+-- +goose StatementEnd
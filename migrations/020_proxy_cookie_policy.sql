@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add sticky cookie policy to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS cookie_policy JSONB;
+-- +goose StatementEnd
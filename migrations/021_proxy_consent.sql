@@ -0,0 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add consent policy to proxies table
+ALTER TABLE proxies ADD COLUMN IF NOT EXISTS consent_policy JSONB;
+-- +goose StatementEnd